@@ -10,11 +10,14 @@ import (
 	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/shopspring/decimal"
 	"github.com/warrior21st/blockchain-utils/ethutil"
+	"github.com/warrior21st/blockchain-utils/multicallutil"
+	"github.com/warrior21st/blockchain-utils/rpcutil"
 	"github.com/warrior21st/blockchain-utils/tokenutil"
 	"github.com/warrior21st/go-utils/commonutil"
 )
@@ -28,28 +31,49 @@ type AirdropParams struct {
 	Token           string
 	TokenDecimals   int64
 	AccountsPerTx   int
+
+	//多节点客户端池,不为空时代替Endpoint使用,节点故障时自动切换
+	Pool *rpcutil.Pool
+	//连接Endpoint时的限速及429重试参数
+	RateLimit *rpcutil.RateLimitParams
+
+	//发送者签名者,不为空时优先于SenderPrv使用
+	Sender ethutil.Signer
+	//不为空时空投交易超时未打包会提高手续费重发,Cancel必须为false
+	Replace *ethutil.ReplaceParams
+
+	//Multicall3合约地址,不为空时通过multicall一次查询发送前的余额和授权额度
+	Multicall string
 }
 
 const AirdropAbi = `[{"anonymous":false,"inputs":[{"indexed":false,"internalType":"address","name":"sender","type":"address"},{"indexed":false,"internalType":"address","name":"token","type":"address"},{"indexed":false,"internalType":"uint256","name":"totalAccount","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"totalAmount","type":"uint256"}],"name":"Aidroped","type":"event"},{"anonymous":false,"inputs":[{"indexed":false,"internalType":"address","name":"caller","type":"address"},{"indexed":false,"internalType":"address","name":"to","type":"address"},{"indexed":false,"internalType":"uint256","name":"amount","type":"uint256"}],"name":"CfoTakedETH","type":"event"},{"anonymous":false,"inputs":[{"indexed":false,"internalType":"address","name":"caller","type":"address"},{"indexed":false,"internalType":"address","name":"token","type":"address"},{"indexed":false,"internalType":"address","name":"to","type":"address"},{"indexed":false,"internalType":"uint256","name":"amount","type":"uint256"}],"name":"CfoTakedToken","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"previousOwner","type":"address"},{"indexed":true,"internalType":"address","name":"newOwner","type":"address"}],"name":"OwnershipTransferred","type":"event"},{"inputs":[{"internalType":"address","name":"_admin","type":"address"}],"name":"addAdmin","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address[]","name":"accounts","type":"address[]"},{"internalType":"uint256[]","name":"amounts","type":"uint256[]"}],"name":"airdropETH","outputs":[],"stateMutability":"payable","type":"function"},{"inputs":[{"internalType":"address","name":"token","type":"address"},{"internalType":"address[]","name":"accounts","type":"address[]"},{"internalType":"uint256[]","name":"amounts","type":"uint256[]"}],"name":"airdropToken","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[],"name":"cfo","outputs":[{"internalType":"address","name":"","type":"address"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"","type":"address"}],"name":"isAdmin","outputs":[{"internalType":"bool","name":"","type":"bool"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"owner","outputs":[{"internalType":"address","name":"","type":"address"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"_admin","type":"address"}],"name":"removeAdmin","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[],"name":"renounceOwnership","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"_cfo","type":"address"}],"name":"setCfo","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"to","type":"address"}],"name":"takeAllETH","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[],"name":"takeAllETHToSelf","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"token","type":"address"},{"internalType":"address","name":"to","type":"address"}],"name":"takeAllToken","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"token","type":"address"}],"name":"takeAllTokenToSelf","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"to","type":"address"},{"internalType":"uint256","name":"amount","type":"uint256"}],"name":"takeETH","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"token","type":"address"},{"internalType":"address","name":"to","type":"address"},{"internalType":"uint256","name":"amount","type":"uint256"}],"name":"takeToken","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"newOwner","type":"address"}],"name":"transferOwnership","outputs":[],"stateMutability":"nonpayable","type":"function"}]`
 
+func (paras *AirdropParams) signer() ethutil.Signer {
+	if paras.Sender != nil {
+		return paras.Sender
+	}
+
+	return ethutil.NewPrivateKeySignerFromHex(paras.SenderPrv)
+}
+
 func AirdropTokensByFile(paras *AirdropParams, airdropListFile string) {
 	accounts, amounts := ReadAirdropList(airdropListFile, paras.TokenDecimals)
 	AirdropTokens(paras, accounts, amounts)
 }
 
 func AirdropTokens(paras *AirdropParams, accounts []common.Address, amounts []*big.Int) {
-	prv := ethutil.HexToECDSAPrivateKey(paras.SenderPrv)
-	sender := ethutil.PubkeyToAddress(&prv.PublicKey)
+	signer := paras.signer()
+	sender := strings.ToLower(signer.Address().Hex())
 	gas := uint(paras.GasLimit)
 	gasPrice := big.NewInt(int64(math.Floor(paras.GasPriceGwei * params.GWei)))
 	// tokenDecimals := paras.TokenDecimals
 	airdropContract := ethutil.GetContractAbi(AirdropAbi)
 
-	client, err := ethclient.Dial(paras.Endpoint)
+	client, closeClient, err := rpcutil.DialClient(paras.Endpoint, paras.Pool, paras.RateLimit)
 	if err != nil {
 		panic(err)
 	}
-	defer client.Close()
+	defer closeClient()
 
 	chainId := ethutil.GetChainID(client)
 
@@ -67,12 +91,12 @@ func AirdropTokens(paras *AirdropParams, accounts []common.Address, amounts []*b
 	ethutil.LogWithTime(fmt.Sprintf("start airdrop accounts count: %d, totalAmount: %s", totalAccount, tokenutil.ConvertAmount(totalAmount, int32(paras.TokenDecimals))))
 
 	nonce := ethutil.GetNextNonce(client, sender)
-	allowanceAmount, err := tokenutil.Allowance(client, paras.Token, sender, paras.AirdropContract)
+	balance, allowanceAmount, err := readSenderTokenState(client, paras, sender)
 	if err != nil {
 		panic(err)
 	}
 	if allowanceAmount.Cmp(totalAmount) == -1 {
-		txId, err := tokenutil.Approve(client, chainId, prv, paras.Token, paras.AirdropContract, nonce, tokenutil.ApproveERC20DefaultGas, gasPrice)
+		txId, err := tokenutil.Approve(client, chainId, signer, paras.Token, paras.AirdropContract, nonce, tokenutil.ApproveERC20DefaultGas, gasPrice)
 		if err != nil {
 			panic(err)
 		}
@@ -83,10 +107,6 @@ func AirdropTokens(paras *AirdropParams, accounts []common.Address, amounts []*b
 		nonce++
 	}
 
-	balance, err := tokenutil.BalanceOf(client, paras.Token, sender)
-	if err != nil {
-		panic(err)
-	}
 	if balance.Cmp(totalAmount) == -1 {
 		panic(errors.New("insufficient sender balance"))
 	}
@@ -104,18 +124,79 @@ func AirdropTokens(paras *AirdropParams, accounts []common.Address, amounts []*b
 			panic(err)
 		}
 		airdropTx := ethutil.NewTx(nonce, paras.AirdropContract, big.NewInt(0), uint64(gas), gasPrice, airdropInputData)
-		airdropSignedTx := ethutil.SignTx(prv, airdropTx, chainId)
-		airdropTxId := ethutil.GetRawTxHash(airdropSignedTx)
-		err = ethutil.SendRawTx(client, airdropSignedTx)
+		sendAirdropTx(client, signer, chainId, airdropTx, paras, fmt.Sprintf("airdrop Tokens for accounts index: %d - %d / %d", i, endIndex-1, totalAccount-1))
+
+		nonce++
+	}
+}
+
+//签名发送空投交易并等待执行成功,设置了Replace时卡住的交易会被加速
+func sendAirdropTx(client *ethclient.Client, signer ethutil.Signer, chainId *big.Int, tx *types.Transaction, paras *AirdropParams, txDesc string) {
+	if paras.Replace == nil {
+		signedTx, err := ethutil.SignTxBySigner(signer, tx, chainId)
+		if err != nil {
+			panic(err)
+		}
+		txId := ethutil.GetRawTxHash(signedTx)
+		err = ethutil.SendRawTx(client, signedTx)
 		if err != nil {
 			panic(err)
 		}
-		ethutil.LogWithTime(fmt.Sprintf("sended airdrop Tokens tx: %s...", airdropTxId))
+		ethutil.LogWithTime(fmt.Sprintf("sended %s tx: %s...", txDesc, txId))
 
-		ethutil.WaitTxReceiptSuccess(client, airdropTxId, fmt.Sprintf("airdrop for accounts index: %d - %d / %d", i, endIndex-1, totalAccount-1), 0)
+		ethutil.WaitTxReceiptSuccess(client, txId, txDesc, 0)
+		return
+	}
 
-		nonce++
+	if paras.Replace.Cancel {
+		panic(errors.New("airdrop replace params can not be cancel"))
+	}
+	result, err := ethutil.SendTxWithReplacement(client, signer, tx, chainId, paras.Replace)
+	if err != nil {
+		panic(err)
+	}
+	txId := result.Tx.Hash().Hex()
+	if result.Receipt.Status != types.ReceiptStatusSuccessful {
+		_, err = ethutil.WaitTxSuccess(client, txId, txDesc, 0)
+		panic(err)
 	}
+	ethutil.LogWithTime(fmt.Sprintf("%s tx %s success", txDesc, txId))
+}
+
+//查询发送者的token余额和对空投合约的授权额度
+func readSenderTokenState(client *ethclient.Client, paras *AirdropParams, sender string) (*big.Int, *big.Int, error) {
+	if !commonutil.IsNilOrWhiteSpace(paras.Multicall) {
+		erc20Abi := ethutil.GetContractAbi(tokenutil.ERC20Abi)
+		balanceData, err := erc20Abi.Pack("balanceOf", common.HexToAddress(sender))
+		if err != nil {
+			return nil, nil, err
+		}
+		allowanceData, err := erc20Abi.Pack("allowance", common.HexToAddress(sender), common.HexToAddress(paras.AirdropContract))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		results, err := multicallutil.Aggregate3(client, paras.Multicall, []multicallutil.Call3{
+			{Target: common.HexToAddress(paras.Token), AllowFailure: false, CallData: balanceData},
+			{Target: common.HexToAddress(paras.Token), AllowFailure: false, CallData: allowanceData},
+		}, multicallutil.DefaultChunkSize)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return big.NewInt(0).SetBytes(results[0].ReturnData), big.NewInt(0).SetBytes(results[1].ReturnData), nil
+	}
+
+	balance, err := tokenutil.BalanceOf(client, paras.Token, sender)
+	if err != nil {
+		return nil, nil, err
+	}
+	allowanceAmount, err := tokenutil.Allowance(client, paras.Token, sender, paras.AirdropContract)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return balance, allowanceAmount, nil
 }
 
 func AirdropETHsByFile(paras *AirdropParams, airdropListFile string) {
@@ -124,17 +205,17 @@ func AirdropETHsByFile(paras *AirdropParams, airdropListFile string) {
 }
 
 func AirdropETHs(paras *AirdropParams, accounts []common.Address, amounts []*big.Int) {
-	prv := ethutil.HexToECDSAPrivateKey(paras.SenderPrv)
-	sender := ethutil.PubkeyToAddress(&prv.PublicKey)
+	signer := paras.signer()
+	sender := strings.ToLower(signer.Address().Hex())
 	gas := uint(paras.GasLimit)
 	gasPrice := big.NewInt(int64(math.Floor(paras.GasPriceGwei * params.GWei)))
 	airdropContract := ethutil.GetContractAbi(AirdropAbi)
 
-	client, err := ethclient.Dial(paras.Endpoint)
+	client, closeClient, err := rpcutil.DialClient(paras.Endpoint, paras.Pool, paras.RateLimit)
 	if err != nil {
 		panic(err)
 	}
-	defer client.Close()
+	defer closeClient()
 
 	chainId := ethutil.GetChainID(client)
 	ethutil.LogWithTime(fmt.Sprintf("airdrop chainId: %s", chainId.String()))
@@ -179,15 +260,7 @@ func AirdropETHs(paras *AirdropParams, accounts []common.Address, amounts []*big
 			panic(err)
 		}
 		airdropTx := ethutil.NewTx(nonce, paras.AirdropContract, periodTotalAmount, uint64(gas), gasPrice, airdropInputData)
-		airdropSignedTx := ethutil.SignTx(prv, airdropTx, chainId)
-		airdropTxId := ethutil.GetRawTxHash(airdropSignedTx)
-		err = ethutil.SendRawTx(client, airdropSignedTx)
-		if err != nil {
-			panic(err)
-		}
-		ethutil.LogWithTime(fmt.Sprintf("sended airdrop ETHs tx: %s...", airdropTxId))
-
-		ethutil.WaitTxReceiptSuccess(client, airdropTxId, fmt.Sprintf("airdrop for accounts index: %d - %d / %d", i, endIndex-1, totalAccount-1), 0)
+		sendAirdropTx(client, signer, chainId, airdropTx, paras, fmt.Sprintf("airdrop ETHs for accounts index: %d - %d / %d", i, endIndex-1, totalAccount-1))
 
 		nonce++
 	}
@@ -248,6 +321,7 @@ func ReadAirdropAddressesOnly(filePath string) []common.Address {
 	return accounts
 }
 
+//逐个查询过滤掉合约地址,地址较多时可传入rpcutil.GetEthClientWithRateLimit创建的限速客户端
 func TrimContractAccount(client *ethclient.Client, allAccountsTemp []common.Address) []common.Address {
 	allAccounts := make([]common.Address, 0)
 	for i := range allAccountsTemp {
@@ -267,6 +341,26 @@ func TrimContractAccount(client *ethclient.Client, allAccountsTemp []common.Addr
 	return allAccounts
 }
 
+//通过json-rpc批量请求过滤掉合约地址,适用于没有部署multicall合约的链
+func TrimContractAccountByBatch(rpcClient *rpc.Client, allAccountsTemp []common.Address, batchSize int) []common.Address {
+	codes, errs := ethutil.BatchGetCode(rpcClient, allAccountsTemp, batchSize)
+	allAccounts := make([]common.Address, 0)
+	for i := range allAccountsTemp {
+		if errs[i] != nil {
+			panic(fmt.Errorf("get %s code err: %s", allAccountsTemp[i].Hex(), errs[i].Error()))
+		}
+		if len(codes[i]) > 0 {
+			ethutil.LogWithTime(fmt.Sprintf("%s is contract address,skip...", allAccountsTemp[i].Hex()))
+			continue
+		}
+
+		allAccounts = append(allAccounts, allAccountsTemp[i])
+	}
+	ethutil.LogWithTime(fmt.Sprintf("non-contract addresses: %d / %d", len(allAccounts), len(allAccountsTemp)))
+
+	return allAccounts
+}
+
 func ReadNFTAirdropAddresssWithAmount(filePath string) (addrs []common.Address, amount []int64) {
 	list := strings.Split(commonutil.ReadFile(filePath), "\n")
 	accounts := make([]common.Address, 0)
@@ -0,0 +1,222 @@
+package airdroputil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/shopspring/decimal"
+	"github.com/warrior21st/blockchain-utils/ethutil"
+	"github.com/warrior21st/blockchain-utils/eventutil"
+	"github.com/warrior21st/blockchain-utils/rpcutil"
+	"github.com/warrior21st/blockchain-utils/tokenutil"
+	"github.com/warrior21st/go-utils/fileutil"
+)
+
+type SnapshotParams struct {
+	Endpoint string
+	Token    string
+	//多节点客户端池,不为空时代替Endpoint使用
+	Pool *rpcutil.Pool
+	//连接Endpoint时的限速及429重试参数
+	RateLimit *rpcutil.RateLimitParams
+	//token部署区块,为0时通过二分查找合约代码确定(需要归档节点)
+	FromBlock uint64
+	//快照区块,为0时为最新区块
+	ToBlock   uint64
+	ChunkSize uint64
+
+	//小于该余额的持有者不写入快照
+	MinBalance *big.Int
+	//排除的地址,如交易对、锁仓合约
+	Exclude []string
+	//随机抽取的持有者数量,在快照区块通过balanceOf校验余额(需要归档节点)
+	VerifySample int
+}
+
+type Holder struct {
+	Address common.Address
+	Balance *big.Int
+}
+
+//通过回放Transfer事件计算快照区块的持有者余额,按余额从大到小排序
+func TakeHolderSnapshot(paras *SnapshotParams) ([]*Holder, error) {
+	client, closeClient, err := rpcutil.DialClient(paras.Endpoint, paras.Pool, paras.RateLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer closeClient()
+
+	toBlock := paras.ToBlock
+	if toBlock == 0 {
+		toBlock, err = client.BlockNumber(context.Background())
+		if err != nil {
+			return nil, err
+		}
+	}
+	fromBlock := paras.FromBlock
+	if fromBlock == 0 {
+		fromBlock, err = FindDeployBlock(client, paras.Token, toBlock)
+		if err != nil {
+			return nil, err
+		}
+		ethutil.LogWithTime(fmt.Sprintf("token %s deployed at block %d", paras.Token, fromBlock))
+	}
+
+	//提前返回时取消扫描,避免扫描协程阻塞
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logs, errCh := eventutil.ScanLogs(ctx, client, &eventutil.ScanParams{
+		Addresses: []string{paras.Token},
+		Abi:       tokenutil.ERC20Abi,
+		Events:    []string{"Transfer"},
+		FromBlock: fromBlock,
+		ToBlock:   toBlock,
+		ChunkSize: paras.ChunkSize,
+	})
+	balances := make(map[common.Address]*big.Int)
+	for l := range logs {
+		var transfer struct {
+			From  common.Address
+			To    common.Address
+			Value *big.Int
+		}
+		err := l.Unpack(&transfer)
+		if err != nil {
+			return nil, fmt.Errorf("unpack transfer %s err: %s", l.TxHash.Hex(), err.Error())
+		}
+		addBalance(balances, transfer.From, new(big.Int).Neg(transfer.Value))
+		addBalance(balances, transfer.To, transfer.Value)
+	}
+	if err := <-errCh; err != nil {
+		return nil, err
+	}
+
+	excludes := make(map[common.Address]bool)
+	excludes[common.Address{}] = true
+	for _, addr := range paras.Exclude {
+		excludes[common.HexToAddress(addr)] = true
+	}
+	holders := make([]*Holder, 0)
+	for addr, balance := range balances {
+		if excludes[addr] || balance.Sign() <= 0 {
+			continue
+		}
+		if paras.MinBalance != nil && balance.Cmp(paras.MinBalance) < 0 {
+			continue
+		}
+		holders = append(holders, &Holder{Address: addr, Balance: balance})
+	}
+	sort.Slice(holders, func(i, j int) bool {
+		c := holders[i].Balance.Cmp(holders[j].Balance)
+		if c == 0 {
+			return strings.Compare(holders[i].Address.Hex(), holders[j].Address.Hex()) < 0
+		}
+		return c > 0
+	})
+	ethutil.LogWithTime(fmt.Sprintf("snapshot token %s at block %d,holders: %d", paras.Token, toBlock, len(holders)))
+
+	if paras.VerifySample > 0 {
+		err = VerifyHolderSnapshot(client, paras.Token, holders, paras.VerifySample, toBlock)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return holders, nil
+}
+
+func addBalance(balances map[common.Address]*big.Int, addr common.Address, amount *big.Int) {
+	balance, ok := balances[addr]
+	if !ok {
+		balance = big.NewInt(0)
+		balances[addr] = balance
+	}
+	balance.Add(balance, amount)
+}
+
+//随机抽取sample个持有者,校验其在快照区块的balanceOf与快照余额一致
+func VerifyHolderSnapshot(client *ethclient.Client, token string, holders []*Holder, sample int, block uint64) error {
+	if sample > len(holders) {
+		sample = len(holders)
+	}
+	contract := ethutil.GetContractAbi(tokenutil.ERC20Abi)
+	tokenAddr := common.HexToAddress(token)
+	mismatches := make([]string, 0)
+	for _, i := range rand.Perm(len(holders))[:sample] {
+		holder := holders[i]
+		data, err := contract.Pack("balanceOf", holder.Address)
+		if err != nil {
+			return err
+		}
+		result, err := client.CallContract(context.Background(), ethereum.CallMsg{To: &tokenAddr, Data: data}, new(big.Int).SetUint64(block))
+		if err != nil {
+			return fmt.Errorf("query %s balance at block %d err: %s", holder.Address.Hex(), block, err.Error())
+		}
+		balance := new(big.Int).SetBytes(result)
+		if balance.Cmp(holder.Balance) != 0 {
+			mismatches = append(mismatches, fmt.Sprintf("%s snapshot %s balanceOf %s", holder.Address.Hex(), holder.Balance.String(), balance.String()))
+		}
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("snapshot balance mismatch: %s", strings.Join(mismatches, "; "))
+	}
+	ethutil.LogWithTime(fmt.Sprintf("verified %d holders balance at block %d", sample, block))
+
+	return nil
+}
+
+//二分查找合约代码首次出现的区块
+func FindDeployBlock(client *ethclient.Client, contract string, toBlock uint64) (uint64, error) {
+	addr := common.HexToAddress(contract)
+	hasCode := func(block uint64) (bool, error) {
+		code, err := client.CodeAt(context.Background(), addr, new(big.Int).SetUint64(block))
+		return len(code) > 0, err
+	}
+
+	ok, err := hasCode(toBlock)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, errors.New("contract not deployed at target block")
+	}
+
+	low, high := uint64(0), toBlock
+	for low < high {
+		mid := (low + high) / 2
+		ok, err := hasCode(mid)
+		if err != nil {
+			return 0, fmt.Errorf("query code at block %d err: %s", mid, err.Error())
+		}
+		if ok {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+
+	return low, nil
+}
+
+//保存为每行 地址,数量 的文件,可直接用ReadAirdropList读取,没有持有者时不写入文件
+func SaveHolderSnapshot(filePath string, holders []*Holder, tokenDecimals int32) error {
+	if len(holders) == 0 {
+		return errors.New("no holders to save")
+	}
+	lines := make([]string, len(holders))
+	for i, holder := range holders {
+		lines[i] = fmt.Sprintf("%s,%s", holder.Address.Hex(), decimal.NewFromBigInt(holder.Balance, -tokenDecimals).String())
+	}
+
+	fileutil.WriteFile(filePath, strings.Join(lines, "\n"))
+
+	return nil
+}
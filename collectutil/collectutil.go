@@ -1,12 +1,15 @@
 package collectutil
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/warrior21st/blockchain-utils/ethutil"
+	"github.com/warrior21st/blockchain-utils/rpcutil"
 	"github.com/warrior21st/blockchain-utils/tokenutil"
 	"github.com/warrior21st/go-utils/commonutil"
 )
@@ -16,19 +19,103 @@ type CollectTokenParams struct {
 	GasPriceGwei float64
 	Token        string
 	IncomeTo     string
+
+	//多节点客户端池,不为空时代替Endpoint使用,节点故障时自动切换
+	Pool *rpcutil.Pool
+	//连接Endpoint时的限速及429重试参数,所有请求都经过限速
+	RateLimit *rpcutil.RateLimitParams
+
+	//CollectAssets一次归集的token地址列表,NativeToken表示原生币
+	Tokens []string
+
+	//为缺少gas的账户补充手续费的私钥
+	GasFunderPrv string
+	//补充手续费账户的签名者,不为空时优先于GasFunderPrv使用
+	GasFunder ethutil.Signer
+	//归集token后是否将账户剩余的原生币归还给补充手续费的账户
+	SweepGasBack bool
+
+	//并发扫描/发送的协程数,<=1时为顺序执行
+	Concurrency int
+	//每秒最多请求数,<=0时不限制;RateLimit为nil时生效,限制通过客户端发出的所有请求,包括查询chainId和等待交易确认
+	RequestsPerSecond float64
+	//等待归集交易确认的超时秒数,为0时使用DefaultTxTimeoutSeconds,超时的交易记为失败
+	TxTimeoutSeconds int64
+
+	//Multicall3合约地址,不为空时通过multicall批量查询余额
+	Multicall string
+
+	//归集原生币的选项,为nil时使用GasPriceGwei发送legacy交易
+	Sweep *SweepOptions
+
+	//每个账户的最小归集数量(最小单位),小于该数量的账户跳过
+	MinAmount *big.Int
+	//按token地址设置的最小归集数量,优先于MinAmount
+	MinAmounts map[string]*big.Int
+	//token价格来源,与MinFeeMultiple同时设置时跳过价值低于手续费MinFeeMultiple倍的账户
+	PriceSource    PriceSource
+	MinFeeMultiple float64
+
+	//归集去向的路由规则,为nil时全部归集到IncomeTo
+	Router IncomeRouter
+	//账户地址(小写)对应的分组,用于GroupRouter
+	AccountTags map[string]string
+}
+
+//等待归集交易确认的默认超时秒数
+const DefaultTxTimeoutSeconds = 600
+
+//连接节点,RateLimit为nil时按RequestsPerSecond限速
+func (p *CollectTokenParams) dialClient() (*ethclient.Client, func(), error) {
+	rateLimit := p.RateLimit
+	if rateLimit == nil && p.RequestsPerSecond > 0 {
+		rateLimit = &rpcutil.RateLimitParams{RequestsPerSecond: p.RequestsPerSecond}
+	}
+
+	return rpcutil.DialClient(p.Endpoint, p.Pool, rateLimit)
+}
+
+func (p *CollectTokenParams) txTimeoutSeconds() int64 {
+	if p.TxTimeoutSeconds == 0 {
+		return DefaultTxTimeoutSeconds
+	}
+	return p.TxTimeoutSeconds
 }
 
-func CollectTokensByFile(collectParams *CollectTokenParams, privsFile string, detailSaveFile string) {
+//归集token,detailSaveFile不为空时将每个账户的归集结果保存到该文件(不包含私钥)
+func CollectTokensByFile(collectParams *CollectTokenParams, privsFile string, detailSaveFile string) []*CollectResult {
 	privs := ethutil.ReadPrivateKeys(privsFile)
-	CollectTokens(collectParams, privs, detailSaveFile)
+	return CollectTokens(collectParams, privs, detailSaveFile)
+}
+
+//从带分组的私钥文件(每行为 私钥,分组)读取账户并归集token,按分组路由归集去向
+func CollectTokensByTaggedFile(collectParams *CollectTokenParams, privsFile string, detailSaveFile string) []*CollectResult {
+	privs, tags := ethutil.ReadPrivateKeysWithTags(privsFile)
+	//不修改调用者的参数,文件中的分组优先于已有的AccountTags
+	paras := *collectParams
+	paras.AccountTags = make(map[string]string)
+	for account, tag := range collectParams.AccountTags {
+		paras.AccountTags[account] = tag
+	}
+	for account, tag := range AccountTagsOf(privs, tags) {
+		paras.AccountTags[account] = tag
+	}
+	return CollectTokens(&paras, privs, detailSaveFile)
 }
 
-func CollectTokens(collectParams *CollectTokenParams, privs []string, detailSaveFile string) {
+//归集token,detailSaveFile不为空时将每个账户的归集结果保存到该文件(不包含私钥)
+func CollectTokens(collectParams *CollectTokenParams, privs []string, detailSaveFile string) []*CollectResult {
+	return CollectTokensFromKeySource(collectParams, HexKeySource(privs), detailSaveFile)
+}
+
+//归集token,私钥从keys按需获取
+func CollectTokensFromKeySource(collectParams *CollectTokenParams, keys KeySource, detailSaveFile string) []*CollectResult {
 
-	client, err := ethclient.Dial(collectParams.Endpoint)
+	client, closeClient, err := collectParams.dialClient()
 	if err != nil {
 		panic(err)
 	}
+	defer closeClient()
 
 	decimals, err := tokenutil.Decimals(client, collectParams.Token)
 	if err != nil {
@@ -40,54 +127,231 @@ func CollectTokens(collectParams *CollectTokenParams, privs []string, detailSave
 		panic(err)
 	}
 
-	total := len(privs)
+	gasPrice := big.NewInt(int64(collectParams.GasPriceGwei * params.GWei))
+	recorder := newResultRecorder(detailSaveFile)
+	defer recorder.close()
+	total := keys.Len()
 	for i := 0; i < total; i++ {
-		priv := ethutil.HexToECDSAPrivateKey(privs[i])
-		addr := ethutil.PubkeyToAddress(&priv.PublicKey)
-		balance, _ := tokenutil.BalanceOf(client, collectParams.Token, addr)
-		if balance.Cmp(big.NewInt(0)) == 1 {
+		//跳过的账户也输出进度
+		func() {
+			addr := keys.Address(i)
+			balance, err := tokenutil.BalanceOf(client, collectParams.Token, addr)
+			if err != nil {
+				ethutil.LogWithTime(fmt.Sprintf("get %s token balance err: %s,skip...", addr, err.Error()))
+				recorder.add(newCollectResult(addr, collectParams.Token, nil).fail(err))
+				return
+			}
+			if balance.Cmp(big.NewInt(0)) == 1 {
+				ethutil.LogWithTime(fmt.Sprintf("%s checked %s %s", addr, tokenutil.ConvertAmount(balance, decimals), tokenSymbol))
+				result := newCollectResult(addr, collectParams.Token, balance)
+
+				legs := routeLegs(collectParams, addr, collectParams.Token, balance)
+				if reason := skipReason(collectParams, collectParams.Token, balance, legsGasFee(legs, tokenutil.TransferERC20DefaultGas, gasPrice)); reason != "" {
+					ethutil.LogWithTime(fmt.Sprintf("%s %s,skip...", addr, reason))
+					recorder.add(result.skip(reason))
+					return
+				}
+
+				nonce := ethutil.GetNextNonce(client, addr)
+				ethutil.LogWithTime(fmt.Sprintf("%s current nonce: %d", addr, nonce))
+
+				legResults, _ := sendTokenLegs(client, keys.Signer(i), result, legs, nonce, tokenutil.TransferERC20DefaultGas, gasPrice)
+				confirmResults(client, collectParams.txTimeoutSeconds(), legResults)
+				recorder.add(legResults...)
+			} else {
+				recorder.add(newCollectResult(addr, collectParams.Token, balance).skip("zero balance"))
+			}
+		}()
+		ethutil.LogWithTime(fmt.Sprintf("scan progress %d/%d...", i, total-1))
+	}
+	results := recorder.all()
+	logSkippedResults(results)
+
+	return results
+}
+
+//先为缺少gas的账户补充所需的手续费,再归集token
+func CollectTokensWithGasFundingByFile(collectParams *CollectTokenParams, privsFile string, detailSaveFile string) []*CollectResult {
+	privs := ethutil.ReadPrivateKeys(privsFile)
+	return CollectTokensWithGasFunding(collectParams, privs, detailSaveFile)
+}
+
+//先为缺少gas的账户补充所需的手续费,再归集token
+func CollectTokensWithGasFunding(collectParams *CollectTokenParams, privs []string, detailSaveFile string) []*CollectResult {
+	return CollectTokensWithGasFundingFromKeySource(collectParams, HexKeySource(privs), detailSaveFile)
+}
+
+//先补充手续费再归集token,私钥从keys按需获取
+func CollectTokensWithGasFundingFromKeySource(collectParams *CollectTokenParams, keys KeySource, detailSaveFile string) []*CollectResult {
+	funderSigner := collectParams.GasFunder
+	if funderSigner == nil {
+		if commonutil.IsNilOrWhiteSpace(collectParams.GasFunderPrv) {
+			panic(errors.New("gas funder private key is empty"))
+		}
+		funderSigner = ethutil.NewPrivateKeySignerFromHex(collectParams.GasFunderPrv)
+	}
+
+	client, closeClient, err := collectParams.dialClient()
+	if err != nil {
+		panic(err)
+	}
+	defer closeClient()
+
+	chainId := ethutil.GetChainID(client)
+	gasPrice := big.NewInt(int64(collectParams.GasPriceGwei * params.GWei))
+
+	decimals, err := tokenutil.Decimals(client, collectParams.Token)
+	if err != nil {
+		panic(err)
+	}
+
+	tokenSymbol, err := tokenutil.Symbol(client, collectParams.Token)
+	if err != nil {
+		panic(err)
+	}
+
+	funder := strings.ToLower(funderSigner.Address().Hex())
+	funderNonce := ethutil.GetNextNonce(client, funder)
+
+	recorder := newResultRecorder(detailSaveFile)
+	defer recorder.close()
+	total := keys.Len()
+	for i := 0; i < total; i++ {
+		//跳过的账户也输出进度
+		func() {
+			addr := keys.Address(i)
+			balance, err := tokenutil.BalanceOf(client, collectParams.Token, addr)
+			if err != nil {
+				ethutil.LogWithTime(fmt.Sprintf("get %s token balance err: %s,skip...", addr, err.Error()))
+				recorder.add(newCollectResult(addr, collectParams.Token, nil).fail(err))
+				return
+			}
+			if balance.Sign() <= 0 {
+				recorder.add(newCollectResult(addr, collectParams.Token, balance).skip("zero balance"))
+				return
+			}
 			ethutil.LogWithTime(fmt.Sprintf("%s checked %s %s", addr, tokenutil.ConvertAmount(balance, decimals), tokenSymbol))
-			commonutil.AppendToFile(detailSaveFile, privs[i]+"\n")
+			result := newCollectResult(addr, collectParams.Token, balance)
+
+			legs := routeLegs(collectParams, addr, collectParams.Token, balance)
+			if len(legs) == 0 {
+				recorder.add(result.skip("no route"))
+				return
+			}
+			gas, err := tokenutil.EstimateTransferGas(client, collectParams.Token, addr, legs[0].To, legs[0].Amount)
+			if err != nil {
+				ethutil.LogWithTime(fmt.Sprintf("estimate %s transfer gas err: %s,use default gas...", addr, err.Error()))
+				gas = tokenutil.TransferERC20DefaultGas
+			}
+			gasFee := legsGasFee(legs, int64(gas), gasPrice)
+			if reason := skipReason(collectParams, collectParams.Token, balance, gasFee); reason != "" {
+				ethutil.LogWithTime(fmt.Sprintf("%s %s,skip...", addr, reason))
+				recorder.add(result.skip(reason))
+				return
+			}
+
+			ethBalance := ethutil.GetBalance(client, addr)
+			if ethBalance.Cmp(gasFee) < 0 {
+				topUpAmount := big.NewInt(0).Sub(gasFee, ethBalance)
+				fundTx := ethutil.NewTx(funderNonce, addr, topUpAmount, uint64(21000), gasPrice, nil)
+				signedFundTx, err := ethutil.SignTxBySigner(funderSigner, fundTx, chainId)
+				if err == nil {
+					err = ethutil.SendRawTx(client, signedFundTx)
+				}
+				if err != nil {
+					ethutil.LogWithTime(fmt.Sprintf("send gas fee to %s err: %s,skip...", addr, err.Error()))
+					recorder.add(result.fail(fmt.Errorf("fund gas fee err: %s", err.Error())))
+					//发送失败时nonce未被使用,重新查询避免本地nonce与链上不一致
+					funderNonce = ethutil.GetNextNonce(client, funder)
+					return
+				}
+				//只有发送成功后才递增补gas账户的nonce
+				funderNonce++
+				fundTxId := ethutil.GetRawTxHash(signedFundTx)
 
+				ethutil.LogWithTime(fmt.Sprintf("sended gas fee %s to %s,tx: %s", ethutil.FromWei(topUpAmount), addr, fundTxId))
+				if !ethutil.WaitTxReceipt(client, fundTxId, fmt.Sprintf("fund gas fee to %s", addr), collectParams.txTimeoutSeconds()) {
+					recorder.add(result.fail(fmt.Errorf("fund gas fee tx %s failed", fundTxId)))
+					return
+				}
+			}
+
+			signer := keys.Signer(i)
 			nonce := ethutil.GetNextNonce(client, addr)
-			ethutil.LogWithTime(fmt.Sprintf("%s current nonce: %d", addr, nonce))
+			legResults, _ := sendTokenLegs(client, signer, result, legs, nonce, int64(gas), gasPrice)
+			confirmResults(client, collectParams.txTimeoutSeconds(), legResults)
+			recorder.add(legResults...)
+			if len(FilterCollectResults(legResults, StatusSuccess)) != len(legResults) {
+				return
+			}
 
-			txId, _ := tokenutil.Transfer(client, priv, collectParams.Token, collectParams.IncomeTo, balance, nonce, tokenutil.TransferERC20DefaultGas, big.NewInt(int64(collectParams.GasPriceGwei*params.GWei)))
-			ethutil.WaitTxReceiptSuccess(client, txId, fmt.Sprintf("income %d %s from %s", tokenutil.ConvertAmount(balance, decimals), tokenSymbol, addr), 0)
-		}
+			if collectParams.SweepGasBack {
+				sweepGasBack(client, chainId, signer, funder, sweepOptions(collectParams))
+			}
+		}()
 		ethutil.LogWithTime(fmt.Sprintf("scan progress %d/%d...", i, total-1))
 	}
+	results := recorder.all()
+	logSkippedResults(results)
+
+	return results
 }
 
-func CollectETHs(collectParams *CollectTokenParams, privs []string) {
-	client, err := ethclient.Dial(collectParams.Endpoint)
+//将账户剩余的原生币归还给补gas账户
+func sweepGasBack(client *ethclient.Client, chainId *big.Int, signer ethutil.Signer, funder string, opts *SweepOptions) {
+	addr := strings.ToLower(signer.Address().Hex())
+	txId, amount, err := SweepETH(client, chainId, signer, funder, opts)
+	if err != nil {
+		ethutil.LogWithTime(fmt.Sprintf("sweep %s gas back err: %s", addr, err.Error()))
+		return
+	}
+	ethutil.LogWithTime(fmt.Sprintf("sended sweep gas back %s tx %s", ethutil.FromWei(amount), txId))
+}
+
+//归集原生币
+func CollectETHs(collectParams *CollectTokenParams, privs []string) []*CollectResult {
+	return CollectETHsFromKeySourceWithDetail(collectParams, HexKeySource(privs), "")
+}
+
+//归集原生币,detailSaveFile不为空时将每个账户的归集结果保存到该文件(不包含私钥)
+func CollectETHsWithDetail(collectParams *CollectTokenParams, privs []string, detailSaveFile string) []*CollectResult {
+	return CollectETHsFromKeySourceWithDetail(collectParams, HexKeySource(privs), detailSaveFile)
+}
+
+//归集原生币,私钥从keys按需获取
+func CollectETHsFromKeySource(collectParams *CollectTokenParams, keys KeySource) []*CollectResult {
+	return CollectETHsFromKeySourceWithDetail(collectParams, keys, "")
+}
+
+//同CollectETHsFromKeySource,detailSaveFile不为空时将每个账户的归集结果保存到该文件(不包含私钥)
+func CollectETHsFromKeySourceWithDetail(collectParams *CollectTokenParams, keys KeySource, detailSaveFile string) []*CollectResult {
+	client, closeClient, err := collectParams.dialClient()
 	if err != nil {
 		panic(err)
 	}
+	defer closeClient()
 
 	chainId := ethutil.GetChainID(client)
-	gasPrice := big.NewInt(int64(collectParams.GasPriceGwei * params.GWei))
+	opts := sweepOptions(collectParams)
 
-	total := len(privs)
+	recorder := newResultRecorder(detailSaveFile)
+	defer recorder.close()
+	total := keys.Len()
 	for i := 0; i < total; i++ {
-		priv := ethutil.HexToECDSAPrivateKey(privs[i])
-		addr := ethutil.PubkeyToAddress(&priv.PublicKey)
+		addr := keys.Address(i)
 		balance := ethutil.GetBalance(client, addr)
-
-		gasFee := big.NewInt(0).Mul(big.NewInt(21000), gasPrice)
-		if balance.Cmp(gasFee) <= 0 {
-			ethutil.LogWithTime(fmt.Sprintf("%s balance less than or equals to gas fee,continue...", addr))
+		if balance.Sign() <= 0 {
+			recorder.add(newCollectResult(addr, "", balance).skip("zero balance"))
 			continue
 		}
 
-		nonce := ethutil.GetNextNonce(client, addr)
-		ethutil.LogWithTime(fmt.Sprintf("%s current nonce: %d", addr, nonce))
-
-		incomeAmount := balance.Sub(balance, gasFee)
-		incomeTx := ethutil.NewTx(nonce, collectParams.IncomeTo, incomeAmount, uint64(21000), gasPrice, nil)
-		signedIncomeTx := ethutil.SignTx(priv, incomeTx, chainId)
-		txId := ethutil.GetRawTxHash(signedIncomeTx)
-		ethutil.SendRawTx(client, signedIncomeTx)
+		legResults := sweepETHLegs(client, chainId, keys.Signer(i), collectParams, newCollectResult(addr, "", balance), opts)
+		recorder.add(legResults...)
+		txId := legResults[len(legResults)-1].TxHash
+		if txId == "" {
+			ethutil.LogWithTime(fmt.Sprintf("%s %s,continue...", addr, legResults[len(legResults)-1].Error))
+			continue
+		}
 
 		ethutil.LogWithTime(fmt.Sprintf("sended tx %s", txId))
 
@@ -97,4 +361,6 @@ func CollectETHs(collectParams *CollectTokenParams, privs []string) {
 
 		ethutil.LogWithTime(fmt.Sprintf("income progress %d/%d...", i, total-1))
 	}
+
+	return recorder.all()
 }
@@ -0,0 +1,160 @@
+package collectutil
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/warrior21st/blockchain-utils/ethutil"
+)
+
+//归集账户的签名者来源,签名者只在需要签名时才获取
+type KeySource interface {
+	Len() int
+	Address(i int) string
+	Signer(i int) ethutil.Signer
+}
+
+//已创建的签名者列表,可混合内存私钥、keystore和远程签名者
+type SignerKeySource []ethutil.Signer
+
+func (s SignerKeySource) Len() int {
+	return len(s)
+}
+
+func (s SignerKeySource) Address(i int) string {
+	return strings.ToLower(s[i].Address().Hex())
+}
+
+func (s SignerKeySource) Signer(i int) ethutil.Signer {
+	return s[i]
+}
+
+//16进制私钥列表
+type HexKeySource []string
+
+func (s HexKeySource) Len() int {
+	return len(s)
+}
+
+func (s HexKeySource) Address(i int) string {
+	return ethutil.GetAddress(s[i])
+}
+
+func (s HexKeySource) Signer(i int) ethutil.Signer {
+	return ethutil.NewPrivateKeySignerFromHex(s[i])
+}
+
+//从助记词按需派生的账户,私钥只保存在内存中
+type MnemonicKeySource struct {
+	seed         []byte
+	pathTemplate string
+	start        int
+	count        int
+
+	addresses map[int]string
+	lock      sync.Mutex
+}
+
+//派生索引范围为[start,end)的账户,pathTemplate中的%d替换为索引,不包含%d时追加/%d
+func NewMnemonicKeySource(mnemonic string, passphrase string, pathTemplate string, start int, end int) *MnemonicKeySource {
+	if !ethutil.IsValidMnemonic(mnemonic) {
+		panic(fmt.Errorf("invalid mnemonic"))
+	}
+	if !strings.Contains(pathTemplate, "%d") {
+		pathTemplate = strings.TrimRight(pathTemplate, "/") + "/%d"
+	}
+
+	return &MnemonicKeySource{
+		seed:         ethutil.MnemonicToSeed(mnemonic, passphrase),
+		pathTemplate: pathTemplate,
+		start:        start,
+		count:        end - start,
+		addresses:    make(map[int]string),
+	}
+}
+
+//从加密保存的助记词keystore文件创建
+func NewMnemonicKeystoreKeySource(keystoreFile string, pwd string, passphrase string, pathTemplate string, start int, end int) *MnemonicKeySource {
+	mnemonic := ethutil.DecryptMnemonicKeystoreFile(keystoreFile, pwd)
+	return NewMnemonicKeySource(mnemonic, passphrase, pathTemplate, start, end)
+}
+
+func (s *MnemonicKeySource) Len() int {
+	return s.count
+}
+
+func (s *MnemonicKeySource) Address(i int) string {
+	s.lock.Lock()
+	addr, ok := s.addresses[i]
+	s.lock.Unlock()
+	if ok {
+		return addr
+	}
+
+	addr = ethutil.PubkeyToAddress(&s.privateKey(i).PublicKey)
+	s.lock.Lock()
+	s.addresses[i] = addr
+	s.lock.Unlock()
+
+	return addr
+}
+
+func (s *MnemonicKeySource) Signer(i int) ethutil.Signer {
+	return ethutil.NewPrivateKeySigner(s.privateKey(i))
+}
+
+func (s *MnemonicKeySource) privateKey(i int) *ecdsa.PrivateKey {
+	path := fmt.Sprintf(s.pathTemplate, s.start+i)
+	prv, err := ethutil.DeriveKeyFromSeed(s.seed, path)
+	if err != nil {
+		panic(fmt.Errorf("derive %s err: %s", path, err.Error()))
+	}
+
+	return prv
+}
+
+//从助记词派生索引[start,end)的账户并归集token,私钥只在内存中派生
+func CollectTokensByMnemonic(collectParams *CollectTokenParams, mnemonic string, passphrase string, pathTemplate string, start int, end int, detailSaveFile string) []*CollectResult {
+	return CollectTokensFromKeySource(collectParams, NewMnemonicKeySource(mnemonic, passphrase, pathTemplate, start, end), detailSaveFile)
+}
+
+//从助记词派生索引[start,end)的账户并归集原生币,私钥只在内存中派生
+func CollectETHsByMnemonic(collectParams *CollectTokenParams, mnemonic string, passphrase string, pathTemplate string, start int, end int) []*CollectResult {
+	return CollectETHsFromKeySource(collectParams, NewMnemonicKeySource(mnemonic, passphrase, pathTemplate, start, end))
+}
+
+//从加密的助记词keystore文件派生索引[start,end)的账户并归集token
+func CollectTokensByMnemonicKeystore(collectParams *CollectTokenParams, keystoreFile string, pwd string, passphrase string, pathTemplate string, start int, end int, detailSaveFile string) []*CollectResult {
+	return CollectTokensFromKeySource(collectParams, NewMnemonicKeystoreKeySource(keystoreFile, pwd, passphrase, pathTemplate, start, end), detailSaveFile)
+}
+
+//从加密的助记词keystore文件派生索引[start,end)的账户并归集原生币
+func CollectETHsByMnemonicKeystore(collectParams *CollectTokenParams, keystoreFile string, pwd string, passphrase string, pathTemplate string, start int, end int) []*CollectResult {
+	return CollectETHsFromKeySource(collectParams, NewMnemonicKeystoreKeySource(keystoreFile, pwd, passphrase, pathTemplate, start, end))
+}
+
+//keystore目录中的账户,私钥在签名时才解密
+type KeystoreKeySource struct {
+	addresses []string
+	accounts  map[string]string
+	pwd       string
+}
+
+func NewKeystoreKeySource(store *ethutil.KeystoreStore, pwd string) *KeystoreKeySource {
+	addrs, accounts := store.Addresses(), store.Accounts()
+	return &KeystoreKeySource{addresses: addrs, accounts: accounts, pwd: pwd}
+}
+
+func (s *KeystoreKeySource) Len() int {
+	return len(s.addresses)
+}
+
+func (s *KeystoreKeySource) Address(i int) string {
+	return s.addresses[i]
+}
+
+func (s *KeystoreKeySource) Signer(i int) ethutil.Signer {
+	return ethutil.NewKeystoreSignerFromFile(s.accounts[s.addresses[i]], s.pwd)
+}
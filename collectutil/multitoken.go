@@ -0,0 +1,247 @@
+package collectutil
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/warrior21st/blockchain-utils/ethutil"
+	"github.com/warrior21st/blockchain-utils/multicallutil"
+	"github.com/warrior21st/blockchain-utils/tokenutil"
+	"github.com/warrior21st/go-utils/commonutil"
+)
+
+//Tokens中表示原生币的标识
+const NativeToken = "native"
+
+//一次扫描中多个token和原生币的余额
+type assetBalances struct {
+	tokens []*big.Int
+	native *big.Int
+	//重试后仍查询失败的错误
+	tokenErrs []error
+	nativeErr error
+}
+
+func CollectAssetsByFile(collectParams *CollectTokenParams, privsFile string, detailSaveFile string) ([]*CollectResult, map[string]*big.Int) {
+	privs := ethutil.ReadPrivateKeys(privsFile)
+	return CollectAssets(collectParams, privs, detailSaveFile)
+}
+
+//一次扫描归集Tokens中的所有token和原生币,每个账户先归集token,最后归集原生币
+//返回每个账户每种资产的归集结果,以及每种资产的归集总量(原生币的key为空字符串)
+func CollectAssets(collectParams *CollectTokenParams, privs []string, detailSaveFile string) ([]*CollectResult, map[string]*big.Int) {
+	return CollectAssetsFromKeySource(collectParams, HexKeySource(privs), detailSaveFile)
+}
+
+//一次扫描归集多种资产,私钥从keys按需获取
+func CollectAssetsFromKeySource(collectParams *CollectTokenParams, keys KeySource, detailSaveFile string) ([]*CollectResult, map[string]*big.Int) {
+	tokens, includeNative := splitAssets(collectParams.Tokens)
+	if len(tokens) == 0 && !includeNative {
+		panic(errors.New("no token to collect"))
+	}
+
+	client, closeClient, err := collectParams.dialClient()
+	if err != nil {
+		panic(err)
+	}
+	defer closeClient()
+
+	chainId := ethutil.GetChainID(client)
+	gasPrice := big.NewInt(int64(collectParams.GasPriceGwei * params.GWei))
+	opts := sweepOptions(collectParams)
+
+	symbols := make([]string, len(tokens))
+	decimals := make([]int32, len(tokens))
+	for j := range tokens {
+		symbols[j], err = tokenutil.Symbol(client, tokens[j])
+		if err != nil {
+			panic(err)
+		}
+		decimals[j], err = tokenutil.Decimals(client, tokens[j])
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	addrs := make([]string, keys.Len())
+	for i := 0; i < keys.Len(); i++ {
+		addrs[i] = keys.Address(i)
+	}
+	balances := scanAssetBalances(client, collectParams, tokens, includeNative, addrs)
+
+	recorder := newResultRecorder(detailSaveFile)
+	defer recorder.close()
+	totals := make(map[string]*big.Int)
+	for _, token := range tokens {
+		totals[token] = big.NewInt(0)
+	}
+	if includeNative {
+		totals[""] = big.NewInt(0)
+	}
+	lock := sync.Mutex{}
+
+	ethutil.RunParallel(keys.Len(), collectParams.Concurrency, func(i int) {
+		signer := keys.Signer(i)
+		accountResults := make([]*CollectResult, 0)
+
+		var nonce uint64
+		nonceLoaded := false
+		for j, token := range tokens {
+			balance := balances[i].tokens[j]
+			if err := balances[i].tokenErrs[j]; err != nil {
+				accountResults = append(accountResults, newCollectResult(addrs[i], token, nil).fail(fmt.Errorf("get balance err: %s", err.Error())))
+				continue
+			}
+			if balance.Sign() <= 0 {
+				accountResults = append(accountResults, newCollectResult(addrs[i], token, balance).skip("zero balance"))
+				continue
+			}
+			ethutil.LogWithTime(fmt.Sprintf("%s checked %s %s", addrs[i], tokenutil.ConvertAmount(balance, decimals[j]), symbols[j]))
+			result := newCollectResult(addrs[i], token, balance)
+			accountResults = append(accountResults, result)
+			legs := routeLegs(collectParams, addrs[i], token, balance)
+			if reason := skipReason(collectParams, token, balance, legsGasFee(legs, tokenutil.TransferERC20DefaultGas, gasPrice)); reason != "" {
+				result.skip(reason)
+				continue
+			}
+
+			if !nonceLoaded {
+				nonce = ethutil.GetNextNonce(client, addrs[i])
+				nonceLoaded = true
+			}
+			var legResults []*CollectResult
+			legResults, nonce = sendTokenLegs(client, signer, result, legs, nonce, tokenutil.TransferERC20DefaultGas, gasPrice)
+			accountResults = append(accountResults, legResults[1:]...)
+		}
+
+		//原生币需要在token归集交易全部上链后再归集,才能精确扣除手续费
+		confirmResults(client, collectParams.txTimeoutSeconds(), accountResults)
+
+		if includeNative {
+			if balances[i].nativeErr != nil {
+				accountResults = append(accountResults, newCollectResult(addrs[i], "", nil).fail(fmt.Errorf("get balance err: %s", balances[i].nativeErr.Error())))
+			} else if balances[i].native.Sign() > 0 {
+				accountResults = append(accountResults, sweepETHLegs(client, chainId, signer, collectParams, newCollectResult(addrs[i], "", balances[i].native), opts)...)
+			} else {
+				accountResults = append(accountResults, newCollectResult(addrs[i], "", balances[i].native).skip("zero balance"))
+			}
+		}
+		recorder.add(accountResults...)
+
+		lock.Lock()
+		defer lock.Unlock()
+		for _, result := range accountResults {
+			if result.Status == StatusSuccess || result.Status == StatusSent {
+				totals[result.Token].Add(totals[result.Token], result.Collected)
+			}
+		}
+	})
+
+	for j, token := range tokens {
+		ethutil.LogWithTime(fmt.Sprintf("collected total %s %s", tokenutil.ConvertAmount(totals[token], decimals[j]), symbols[j]))
+	}
+	if includeNative {
+		ethutil.LogWithTime(fmt.Sprintf("collected total %s native coin", ethutil.FromWei(totals[""])))
+	}
+	results := recorder.all()
+	logSkippedResults(results)
+
+	return results, totals
+}
+
+//拆分token地址列表和是否包含原生币
+func splitAssets(assets []string) ([]string, bool) {
+	tokens := make([]string, 0)
+	includeNative := false
+	for _, asset := range assets {
+		if strings.EqualFold(asset, NativeToken) {
+			includeNative = true
+		} else {
+			tokens = append(tokens, asset)
+		}
+	}
+
+	return tokens, includeNative
+}
+
+//一次扫描所有账户的多个token余额和原生币余额
+func scanAssetBalances(client *ethclient.Client, collectParams *CollectTokenParams, tokens []string, includeNative bool, accounts []string) []*assetBalances {
+	balances := make([]*assetBalances, len(accounts))
+	for i := range accounts {
+		balances[i] = &assetBalances{tokens: make([]*big.Int, len(tokens)), tokenErrs: make([]error, len(tokens))}
+	}
+
+	if !commonutil.IsNilOrWhiteSpace(collectParams.Multicall) {
+		err := multicallAssetBalances(client, collectParams.Multicall, tokens, includeNative, accounts, balances)
+		if err != nil {
+			ethutil.LogWithTime(fmt.Sprintf("multicall asset balances err: %s,fallback to single calls...", err.Error()))
+		}
+	}
+
+	ethutil.RunParallel(len(accounts), collectParams.Concurrency, func(i int) {
+		for j := range tokens {
+			if balances[i].tokens[j] == nil {
+				balances[i].tokens[j], balances[i].tokenErrs[j] = queryBalance(accounts[i], nil, tokenBalanceQuery(client, tokens[j]))
+			}
+		}
+		if includeNative && balances[i].native == nil {
+			balances[i].native, balances[i].nativeErr = queryBalance(accounts[i], nil, ethBalanceQuery(client))
+		}
+	})
+
+	return balances
+}
+
+//通过multicall查询余额,查询失败的项保留为nil
+func multicallAssetBalances(client *ethclient.Client, multicall string, tokens []string, includeNative bool, accounts []string, balances []*assetBalances) error {
+	erc20Abi := ethutil.GetContractAbi(tokenutil.ERC20Abi)
+	multicallAbi := ethutil.GetContractAbi(multicallutil.Multicall3Abi)
+
+	calls := make([]multicallutil.Call3, 0)
+	for i := range accounts {
+		account := common.HexToAddress(accounts[i])
+		for j := range tokens {
+			callData, err := erc20Abi.Pack("balanceOf", account)
+			if err != nil {
+				return err
+			}
+			calls = append(calls, multicallutil.Call3{Target: common.HexToAddress(tokens[j]), AllowFailure: true, CallData: callData})
+		}
+		if includeNative {
+			callData, err := multicallAbi.Pack("getEthBalance", account)
+			if err != nil {
+				return err
+			}
+			calls = append(calls, multicallutil.Call3{Target: common.HexToAddress(multicall), AllowFailure: true, CallData: callData})
+		}
+	}
+
+	results, err := multicallutil.Aggregate3(client, multicall, calls, multicallutil.DefaultChunkSize)
+	if err != nil {
+		return err
+	}
+
+	n := 0
+	for i := range accounts {
+		for j := range tokens {
+			if results[n].Success && len(results[n].ReturnData) == 32 {
+				balances[i].tokens[j] = big.NewInt(0).SetBytes(results[n].ReturnData)
+			}
+			n++
+		}
+		if includeNative {
+			if results[n].Success && len(results[n].ReturnData) == 32 {
+				balances[i].native = big.NewInt(0).SetBytes(results[n].ReturnData)
+			}
+			n++
+		}
+	}
+
+	return nil
+}
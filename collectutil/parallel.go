@@ -0,0 +1,304 @@
+package collectutil
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/warrior21st/blockchain-utils/ethutil"
+	"github.com/warrior21st/blockchain-utils/multicallutil"
+	"github.com/warrior21st/blockchain-utils/tokenutil"
+	"github.com/warrior21st/go-utils/commonutil"
+)
+
+type rateLimiter struct {
+	ticker *time.Ticker
+}
+
+func newRateLimiter(requestsPerSecond float64) *rateLimiter {
+	if requestsPerSecond <= 0 {
+		return nil
+	}
+
+	//requestsPerSecond过大时间隔为0,NewTicker会panic
+	interval := time.Duration(float64(time.Second) / requestsPerSecond)
+	if interval < time.Microsecond {
+		interval = time.Microsecond
+	}
+
+	return &rateLimiter{ticker: time.NewTicker(interval)}
+}
+
+func (l *rateLimiter) wait() {
+	if l == nil {
+		return
+	}
+	<-l.ticker.C
+}
+
+func (l *rateLimiter) stop() {
+	if l == nil {
+		return
+	}
+	l.ticker.Stop()
+}
+
+//余额查询失败时的最大重试次数,超过后该账户的余额为nil
+const DefaultScanRetries = 5
+
+var scanRetryInterval = time.Second
+
+//并发查询多个账户的token余额,返回结果与accounts顺序一致,重试DefaultScanRetries次仍失败的账户余额为nil
+//requestsPerSecond只限制这里的余额查询,需要限制客户端的所有请求时使用rpcutil.DialClient连接
+func ScanTokenBalances(client *ethclient.Client, token string, accounts []string, concurrency int, requestsPerSecond float64) []*big.Int {
+	balances, _ := scanBalances(accounts, concurrency, requestsPerSecond, tokenBalanceQuery(client, token))
+	return balances
+}
+
+//并发查询多个账户的原生币余额,返回结果与accounts顺序一致,requestsPerSecond和查询失败的处理同ScanTokenBalances
+func ScanETHBalances(client *ethclient.Client, accounts []string, concurrency int, requestsPerSecond float64) []*big.Int {
+	balances, _ := scanBalances(accounts, concurrency, requestsPerSecond, ethBalanceQuery(client))
+	return balances
+}
+
+func tokenBalanceQuery(client *ethclient.Client, token string) func(account string) (*big.Int, error) {
+	return func(account string) (*big.Int, error) {
+		return tokenutil.BalanceOf(client, token, account)
+	}
+}
+
+func ethBalanceQuery(client *ethclient.Client) func(account string) (*big.Int, error) {
+	return func(account string) (*big.Int, error) {
+		return client.BalanceAt(context.Background(), common.HexToAddress(account), nil)
+	}
+}
+
+//并发查询余额,返回每个账户的余额和重试后仍失败的错误
+func scanBalances(accounts []string, concurrency int, requestsPerSecond float64, query func(account string) (*big.Int, error)) ([]*big.Int, []error) {
+	limiter := newRateLimiter(requestsPerSecond)
+	defer limiter.stop()
+
+	total := len(accounts)
+	balances := make([]*big.Int, total)
+	errs := make([]error, total)
+	scanned := int64(0)
+	lock := sync.Mutex{}
+	ethutil.RunParallel(total, concurrency, func(i int) {
+		balances[i], errs[i] = queryBalance(accounts[i], limiter, query)
+
+		lock.Lock()
+		scanned++
+		if scanned%100 == 0 || scanned == int64(total) {
+			ethutil.LogWithTime(fmt.Sprintf("scan progress %d/%d...", scanned, total))
+		}
+		lock.Unlock()
+	})
+
+	return balances, errs
+}
+
+//查询失败时间隔scanRetryInterval重试,最多重试DefaultScanRetries次
+func queryBalance(account string, limiter *rateLimiter, query func(account string) (*big.Int, error)) (*big.Int, error) {
+	limiter.wait()
+	balance, err := query(account)
+	for retries := 0; err != nil && retries < DefaultScanRetries; retries++ {
+		ethutil.LogWithTime(fmt.Sprintf("get %s balance err: %s,sleep %s...", account, err.Error(), scanRetryInterval))
+		time.Sleep(scanRetryInterval)
+		limiter.wait()
+		balance, err = query(account)
+	}
+	if err != nil {
+		ethutil.LogWithTime(fmt.Sprintf("get %s balance err: %s,give up after %d retries", account, err.Error(), DefaultScanRetries))
+		return nil, err
+	}
+
+	return balance, nil
+}
+
+//配置了Multicall时通过multicall批量查询token余额,查询失败的账户再单独查询
+func scanTokenBalances(client *ethclient.Client, collectParams *CollectTokenParams, accounts []string) ([]*big.Int, []error) {
+	query := tokenBalanceQuery(client, collectParams.Token)
+	if commonutil.IsNilOrWhiteSpace(collectParams.Multicall) {
+		return scanBalances(accounts, collectParams.Concurrency, 0, query)
+	}
+
+	balances, err := multicallutil.BalancesOf(client, collectParams.Multicall, collectParams.Token, accounts, multicallutil.DefaultChunkSize)
+	if err != nil {
+		ethutil.LogWithTime(fmt.Sprintf("multicall token balances err: %s,fallback to single calls...", err.Error()))
+		return scanBalances(accounts, collectParams.Concurrency, 0, query)
+	}
+	errs := make([]error, len(accounts))
+	for i := range balances {
+		if balances[i] == nil {
+			balances[i], errs[i] = queryBalance(accounts[i], nil, query)
+		}
+	}
+
+	return balances, errs
+}
+
+//配置了Multicall时通过multicall批量查询原生币余额,查询失败的账户再单独查询
+func scanETHBalances(client *ethclient.Client, collectParams *CollectTokenParams, accounts []string) ([]*big.Int, []error) {
+	query := ethBalanceQuery(client)
+	if commonutil.IsNilOrWhiteSpace(collectParams.Multicall) {
+		return scanBalances(accounts, collectParams.Concurrency, 0, query)
+	}
+
+	balances, err := multicallutil.EthBalances(client, collectParams.Multicall, accounts, multicallutil.DefaultChunkSize)
+	if err != nil {
+		ethutil.LogWithTime(fmt.Sprintf("multicall eth balances err: %s,fallback to single calls...", err.Error()))
+		return scanBalances(accounts, collectParams.Concurrency, 0, query)
+	}
+	errs := make([]error, len(accounts))
+	for i := range balances {
+		if balances[i] == nil {
+			balances[i], errs[i] = queryBalance(accounts[i], nil, query)
+		}
+	}
+
+	return balances, errs
+}
+
+func CollectTokensParallelByFile(collectParams *CollectTokenParams, privsFile string, detailSaveFile string) []*CollectResult {
+	privs := ethutil.ReadPrivateKeys(privsFile)
+	return CollectTokensParallel(collectParams, privs, detailSaveFile)
+}
+
+//并发扫描余额,并从不同账户并发发送归集交易
+func CollectTokensParallel(collectParams *CollectTokenParams, privs []string, detailSaveFile string) []*CollectResult {
+	return CollectTokensParallelFromKeySource(collectParams, HexKeySource(privs), detailSaveFile)
+}
+
+//并发归集token,私钥从keys按需获取
+func CollectTokensParallelFromKeySource(collectParams *CollectTokenParams, keys KeySource, detailSaveFile string) []*CollectResult {
+	client, closeClient, err := collectParams.dialClient()
+	if err != nil {
+		panic(err)
+	}
+	defer closeClient()
+
+	gasPrice := big.NewInt(int64(collectParams.GasPriceGwei * params.GWei))
+
+	decimals, err := tokenutil.Decimals(client, collectParams.Token)
+	if err != nil {
+		panic(err)
+	}
+
+	tokenSymbol, err := tokenutil.Symbol(client, collectParams.Token)
+	if err != nil {
+		panic(err)
+	}
+
+	addrs := make([]string, keys.Len())
+	for i := 0; i < keys.Len(); i++ {
+		addrs[i] = keys.Address(i)
+	}
+	balances, errs := scanTokenBalances(client, collectParams, addrs)
+
+	recorder := newResultRecorder(detailSaveFile)
+	defer recorder.close()
+	accountResults := make([][]*CollectResult, keys.Len())
+	pending := make([]int, 0)
+	for i := range balances {
+		if errs[i] != nil {
+			accountResults[i] = []*CollectResult{newCollectResult(addrs[i], collectParams.Token, nil).fail(fmt.Errorf("get balance err: %s", errs[i].Error()))}
+			recorder.add(accountResults[i]...)
+		} else if balances[i].Sign() > 0 {
+			ethutil.LogWithTime(fmt.Sprintf("%s checked %s %s", addrs[i], tokenutil.ConvertAmount(balances[i], decimals), tokenSymbol))
+			pending = append(pending, i)
+		} else {
+			accountResults[i] = []*CollectResult{newCollectResult(addrs[i], collectParams.Token, balances[i]).skip("zero balance")}
+			recorder.add(accountResults[i]...)
+		}
+	}
+	ethutil.LogWithTime(fmt.Sprintf("accounts to collect: %d / %d", len(pending), keys.Len()))
+
+	ethutil.RunParallel(len(pending), collectParams.Concurrency, func(n int) {
+		i := pending[n]
+		result := newCollectResult(addrs[i], collectParams.Token, balances[i])
+		legs := routeLegs(collectParams, addrs[i], collectParams.Token, balances[i])
+		if reason := skipReason(collectParams, collectParams.Token, balances[i], legsGasFee(legs, tokenutil.TransferERC20DefaultGas, gasPrice)); reason != "" {
+			accountResults[i] = []*CollectResult{result.skip(reason)}
+			recorder.add(accountResults[i]...)
+			return
+		}
+		signer := keys.Signer(i)
+
+		nonce := ethutil.GetNextNonce(client, addrs[i])
+		accountResults[i], _ = sendTokenLegs(client, signer, result, legs, nonce, tokenutil.TransferERC20DefaultGas, gasPrice)
+		confirmResults(client, collectParams.txTimeoutSeconds(), accountResults[i])
+		recorder.add(accountResults[i]...)
+	})
+	results := flattenResults(accountResults)
+	logSkippedResults(results)
+
+	return results
+}
+
+//并发扫描余额,并从不同账户并发归集原生币
+func CollectETHsParallel(collectParams *CollectTokenParams, privs []string) []*CollectResult {
+	return CollectETHsParallelFromKeySourceWithDetail(collectParams, HexKeySource(privs), "")
+}
+
+//同CollectETHsParallel,detailSaveFile不为空时将每个账户的归集结果保存到该文件(不包含私钥)
+func CollectETHsParallelWithDetail(collectParams *CollectTokenParams, privs []string, detailSaveFile string) []*CollectResult {
+	return CollectETHsParallelFromKeySourceWithDetail(collectParams, HexKeySource(privs), detailSaveFile)
+}
+
+//并发归集原生币,私钥从keys按需获取
+func CollectETHsParallelFromKeySource(collectParams *CollectTokenParams, keys KeySource) []*CollectResult {
+	return CollectETHsParallelFromKeySourceWithDetail(collectParams, keys, "")
+}
+
+//同CollectETHsParallelFromKeySource,detailSaveFile不为空时将每个账户的归集结果保存到该文件(不包含私钥)
+func CollectETHsParallelFromKeySourceWithDetail(collectParams *CollectTokenParams, keys KeySource, detailSaveFile string) []*CollectResult {
+	client, closeClient, err := collectParams.dialClient()
+	if err != nil {
+		panic(err)
+	}
+	defer closeClient()
+
+	chainId := ethutil.GetChainID(client)
+	opts := sweepOptions(collectParams)
+
+	addrs := make([]string, keys.Len())
+	for i := 0; i < keys.Len(); i++ {
+		addrs[i] = keys.Address(i)
+	}
+	balances, errs := scanETHBalances(client, collectParams, addrs)
+
+	recorder := newResultRecorder(detailSaveFile)
+	defer recorder.close()
+	accountResults := make([][]*CollectResult, keys.Len())
+	ethutil.RunParallel(keys.Len(), collectParams.Concurrency, func(i int) {
+		result := newCollectResult(addrs[i], "", balances[i])
+		if errs[i] != nil {
+			accountResults[i] = []*CollectResult{result.fail(fmt.Errorf("get balance err: %s", errs[i].Error()))}
+			recorder.add(accountResults[i]...)
+			return
+		}
+		if balances[i].Sign() <= 0 {
+			accountResults[i] = []*CollectResult{result.skip("zero balance")}
+			recorder.add(accountResults[i]...)
+			return
+		}
+		signer := keys.Signer(i)
+
+		accountResults[i] = sweepETHLegs(client, chainId, signer, collectParams, result, opts)
+		for _, r := range accountResults[i] {
+			if r.Status == StatusFailed {
+				ethutil.LogWithTime(fmt.Sprintf("%s sweep to %s err: %s", addrs[i], r.To, r.Error))
+			} else if r.Status == StatusSent {
+				ethutil.LogWithTime(fmt.Sprintf("sended tx %s", r.TxHash))
+			}
+		}
+		recorder.add(accountResults[i]...)
+	})
+
+	return flattenResults(accountResults)
+}
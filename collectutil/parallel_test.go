@@ -0,0 +1,56 @@
+package collectutil
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScanBalancesRetries(t *testing.T) {
+	interval := scanRetryInterval
+	scanRetryInterval = time.Millisecond
+	defer func() { scanRetryInterval = interval }()
+
+	calls := make(map[string]int)
+	lock := sync.Mutex{}
+	query := func(account string) (*big.Int, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		calls[account]++
+		switch {
+		case account == "0xrevert":
+			return nil, errors.New("execution reverted")
+		case account == "0xflaky" && calls[account] <= 2:
+			return nil, errors.New("connection refused")
+		}
+		return big.NewInt(int64(len(account))), nil
+	}
+
+	accounts := []string{"0xa", "0xrevert", "0xflaky", "0xbb"}
+	balances, errs := scanBalances(accounts, 2, 0, query)
+
+	tests := []struct {
+		account string
+		balance *big.Int
+		failed  bool
+		calls   int
+	}{
+		{"0xa", big.NewInt(3), false, 1},
+		{"0xrevert", nil, true, DefaultScanRetries + 1},
+		{"0xflaky", big.NewInt(7), false, 3},
+		{"0xbb", big.NewInt(4), false, 1},
+	}
+	for i, tt := range tests {
+		if (errs[i] != nil) != tt.failed {
+			t.Errorf("%s err = %v, want failed %v", tt.account, errs[i], tt.failed)
+		}
+		if (balances[i] == nil) != (tt.balance == nil) || (tt.balance != nil && balances[i].Cmp(tt.balance) != 0) {
+			t.Errorf("%s balance = %v, want %v", tt.account, balances[i], tt.balance)
+		}
+		if calls[tt.account] != tt.calls {
+			t.Errorf("%s queried %d times, want %d", tt.account, calls[tt.account], tt.calls)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package collectutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/warrior21st/go-utils/commonutil"
+	"github.com/warrior21st/go-utils/fileutil"
+)
+
+const (
+	StatusSuccess = "success"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+//单个账户的归集结果,不包含私钥
+type CollectResult struct {
+	Address string `json:"address"`
+	//token地址,归集原生币时为空
+	Token         string   `json:"token"`
+	BalanceBefore *big.Int `json:"balanceBefore"`
+	Collected     *big.Int `json:"collected"`
+	To            string   `json:"to"`
+	TxHash        string   `json:"txHash"`
+	Status        string   `json:"status"`
+	Error         string   `json:"error"`
+}
+
+func newCollectResult(addr string, token string, balance *big.Int) *CollectResult {
+	return &CollectResult{
+		Address:       addr,
+		Token:         token,
+		BalanceBefore: balance,
+		Collected:     big.NewInt(0),
+	}
+}
+
+func (r *CollectResult) fail(err error) *CollectResult {
+	r.Status = StatusFailed
+	r.Error = err.Error()
+	return r
+}
+
+func (r *CollectResult) skip(reason string) *CollectResult {
+	r.Status = StatusSkipped
+	r.Error = reason
+	return r
+}
+
+func (r *CollectResult) sent(txId string, amount *big.Int) *CollectResult {
+	r.Status = StatusSent
+	r.TxHash = txId
+	r.Collected = amount
+	return r
+}
+
+//按等待交易回执的结果更新状态,失败时记录revert原因
+func (r *CollectResult) confirm(err error) *CollectResult {
+	if err == nil {
+		r.Status = StatusSuccess
+	} else {
+		r.Status = StatusFailed
+		r.Error = err.Error()
+		r.Collected = big.NewInt(0)
+	}
+	return r
+}
+
+func (r *CollectResult) isSuccess() bool {
+	return r.Status == StatusSuccess
+}
+
+//按状态筛选归集结果
+func FilterCollectResults(results []*CollectResult, status string) []*CollectResult {
+	filtered := make([]*CollectResult, 0)
+	for _, r := range results {
+		if r.Status == status {
+			filtered = append(filtered, r)
+		}
+	}
+
+	return filtered
+}
+
+//保存归集结果,.json后缀的文件保存为json,其余保存为csv
+func SaveCollectResults(filePath string, results []*CollectResult) {
+	if strings.EqualFold(filepath.Ext(filePath), ".json") {
+		SaveCollectResultsJSON(filePath, results)
+	} else {
+		SaveCollectResultsCSV(filePath, results)
+	}
+}
+
+//先写入临时文件再重命名,写入中途退出不会损坏已有文件
+func SaveCollectResultsJSON(filePath string, results []*CollectResult) {
+	content, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	tmpFile := filePath + ".tmp"
+	fileutil.WriteFile(tmpFile, string(content))
+	err = os.Rename(tmpFile, filePath)
+	if err != nil {
+		panic(err)
+	}
+}
+
+const collectResultsCSVHeader = "address,token,balanceBefore,collected,to,txHash,status,error\n"
+
+func SaveCollectResultsCSV(filePath string, results []*CollectResult) {
+	sb := strings.Builder{}
+	sb.WriteString(collectResultsCSVHeader)
+	for _, r := range results {
+		sb.WriteString(collectResultCSVLine(r))
+	}
+
+	fileutil.WriteFile(filePath, sb.String())
+}
+
+func collectResultCSVLine(r *CollectResult) string {
+	return fmt.Sprintf("%s,%s,%s,%s,%s,%s,%s,%s\n", r.Address, r.Token, bigIntString(r.BalanceBefore), bigIntString(r.Collected), r.To, r.TxHash, r.Status, csvEscape(r.Error))
+}
+
+//逐个账户保存归集结果,中途panic时已处理账户的结果不会丢失
+//csv文件每处理完一个账户追加一次,文件为空时先写入表头;json文件在close时一次写入,调用者须defer close
+type resultRecorder struct {
+	filePath string
+	results  []*CollectResult
+	lock     sync.Mutex
+}
+
+func newResultRecorder(filePath string) *resultRecorder {
+	return &resultRecorder{filePath: filePath, results: make([]*CollectResult, 0)}
+}
+
+//记录一个账户处理完成后的结果
+func (r *resultRecorder) add(results ...*CollectResult) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.results = append(r.results, results...)
+	if commonutil.IsNilOrWhiteSpace(r.filePath) || len(results) == 0 || r.isJSON() {
+		return
+	}
+
+	sb := strings.Builder{}
+	if info, err := os.Stat(r.filePath); err != nil || info.Size() == 0 {
+		sb.WriteString(collectResultsCSVHeader)
+	}
+	for _, result := range results {
+		sb.WriteString(collectResultCSVLine(result))
+	}
+	commonutil.AppendToFile(r.filePath, sb.String())
+}
+
+//json文件写入已记录的全部结果,归集中途panic时也会在defer中执行
+func (r *resultRecorder) close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !commonutil.IsNilOrWhiteSpace(r.filePath) && r.isJSON() {
+		SaveCollectResultsJSON(r.filePath, r.results)
+	}
+}
+
+func (r *resultRecorder) isJSON() bool {
+	return strings.EqualFold(filepath.Ext(r.filePath), ".json")
+}
+
+//已记录的全部结果
+func (r *resultRecorder) all() []*CollectResult {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.results
+}
+
+func flattenResults(accountResults [][]*CollectResult) []*CollectResult {
+	results := make([]*CollectResult, 0)
+	for _, r := range accountResults {
+		results = append(results, r...)
+	}
+
+	return results
+}
+
+func bigIntString(i *big.Int) string {
+	if i == nil {
+		return ""
+	}
+	return i.String()
+}
+
+func csvEscape(s string) string {
+	if strings.ContainsAny(s, ",\"\n") {
+		return "\"" + strings.Replace(s, "\"", "\"\"", -1) + "\""
+	}
+	return s
+}
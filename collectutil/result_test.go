@@ -0,0 +1,75 @@
+package collectutil
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testCollectResults() []*CollectResult {
+	return []*CollectResult{
+		newCollectResult("0xa", "0xt", big.NewInt(10)).sent("0x01", big.NewInt(10)),
+		newCollectResult("0xb", "0xt", big.NewInt(0)).skip("zero balance"),
+		newCollectResult("0xc", "0xt", nil).fail(errors.New("get balance err: a,\"b\"")),
+	}
+}
+
+func TestResultRecorderCSV(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "results.csv")
+	recorder := newResultRecorder(filePath)
+	for _, result := range testCollectResults() {
+		recorder.add(result)
+	}
+	recorder.close()
+
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := collectResultsCSVHeader +
+		"0xa,0xt,10,10,,0x01,sent,\n" +
+		"0xb,0xt,0,0,,,skipped,zero balance\n" +
+		"0xc,0xt,,0,,,failed,\"get balance err: a,\"\"b\"\"\"\n"
+	if string(content) != want {
+		t.Errorf("content = %q, want %q", content, want)
+	}
+}
+
+func TestResultRecorderJSON(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "results.json")
+
+	//归集中途panic时defer的close仍会写入已记录的结果
+	func() {
+		defer func() { recover() }()
+		recorder := newResultRecorder(filePath)
+		defer recorder.close()
+		recorder.add(testCollectResults()[:2]...)
+		if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+			t.Errorf("json file written before close")
+		}
+		panic("collect err")
+	}()
+
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []*CollectResult
+	if err := json.Unmarshal(content, &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Address != "0xa" || results[1].Status != StatusSkipped {
+		t.Errorf("results = %s", content)
+	}
+	if strings.Contains(string(content), "privateKey") {
+		t.Errorf("results contain private keys")
+	}
+	if _, err := os.Stat(filePath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("tmp file left behind")
+	}
+}
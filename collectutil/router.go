@@ -0,0 +1,241 @@
+package collectutil
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/warrior21st/blockchain-utils/ethutil"
+	"github.com/warrior21st/blockchain-utils/tokenutil"
+)
+
+//一笔归集的去向
+type RouteLeg struct {
+	To     string
+	Amount *big.Int
+}
+
+//归集去向的路由规则
+type IncomeRouter interface {
+	//返回账户account(分组为tag)归集数量为amount的token的去向,token为空表示原生币
+	Route(account string, tag string, token string, amount *big.Int) []RouteLeg
+}
+
+//归集到单个地址
+type AddressRouter string
+
+func (r AddressRouter) Route(account string, tag string, token string, amount *big.Int) []RouteLeg {
+	return []RouteLeg{{To: string(r), Amount: amount}}
+}
+
+//按百分比拆分到多个地址,最后一个地址获得拆分后的余数
+type SplitRouter struct {
+	Destinations []string
+	//与Destinations一一对应,总和为100
+	Percents []float64
+}
+
+func NewSplitRouter(destinations []string, percents []float64) *SplitRouter {
+	if len(destinations) == 0 || len(destinations) != len(percents) {
+		panic(errors.New("destinations length not equals to percents length"))
+	}
+	sum := float64(0)
+	for _, p := range percents {
+		sum += p
+	}
+	if sum < 99.9999 || sum > 100.0001 {
+		panic(fmt.Errorf("sum of percents is %v,not 100", sum))
+	}
+
+	return &SplitRouter{Destinations: destinations, Percents: percents}
+}
+
+func (r *SplitRouter) Route(account string, tag string, token string, amount *big.Int) []RouteLeg {
+	legs := make([]RouteLeg, 0, len(r.Destinations))
+	remain := big.NewInt(0).Set(amount)
+	for i := range r.Destinations {
+		legAmount := remain
+		if i < len(r.Destinations)-1 {
+			//按万分比计算
+			legAmount = big.NewInt(0).Mul(amount, big.NewInt(int64(math.Round(r.Percents[i]*100))))
+			legAmount.Div(legAmount, big.NewInt(10000))
+			remain = big.NewInt(0).Sub(remain, legAmount)
+		}
+		if legAmount.Sign() > 0 {
+			legs = append(legs, RouteLeg{To: r.Destinations[i], Amount: legAmount})
+		}
+	}
+
+	return legs
+}
+
+//在多个地址间轮流归集
+type RotateRouter struct {
+	Destinations []string
+
+	next int
+	lock sync.Mutex
+}
+
+func NewRotateRouter(destinations []string) *RotateRouter {
+	if len(destinations) == 0 {
+		panic(errors.New("destinations is empty"))
+	}
+
+	return &RotateRouter{Destinations: destinations}
+}
+
+func (r *RotateRouter) Route(account string, tag string, token string, amount *big.Int) []RouteLeg {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	to := r.Destinations[r.next%len(r.Destinations)]
+	r.next++
+	return []RouteLeg{{To: to, Amount: amount}}
+}
+
+//按账户分组选择路由规则,未匹配的分组使用Default
+type GroupRouter struct {
+	Routes  map[string]IncomeRouter
+	Default IncomeRouter
+}
+
+func (r *GroupRouter) Route(account string, tag string, token string, amount *big.Int) []RouteLeg {
+	if router, ok := r.Routes[tag]; ok {
+		return router.Route(account, tag, token, amount)
+	}
+	if r.Default == nil {
+		return nil
+	}
+	return r.Default.Route(account, tag, token, amount)
+}
+
+//根据地址和分组生成AccountTags
+func AccountTagsOf(privs []string, tags []string) map[string]string {
+	accountTags := make(map[string]string)
+	for i := range privs {
+		accountTags[ethutil.GetAddress(privs[i])] = tags[i]
+	}
+
+	return accountTags
+}
+
+//按归集参数获取归集去向
+func routeLegs(collectParams *CollectTokenParams, account string, token string, amount *big.Int) []RouteLeg {
+	if collectParams.Router == nil {
+		return []RouteLeg{{To: collectParams.IncomeTo, Amount: amount}}
+	}
+
+	return collectParams.Router.Route(account, collectParams.AccountTags[strings.ToLower(account)], token, amount)
+}
+
+//每个去向一笔交易的总手续费
+func legsGasFee(legs []RouteLeg, gas int64, gasPrice *big.Int) *big.Int {
+	return big.NewInt(0).Mul(big.NewInt(gas*int64(len(legs))), gasPrice)
+}
+
+//从nonce开始依次发送每个去向的token归集交易,返回每笔交易的归集结果和下一个nonce
+func sendTokenLegs(client *ethclient.Client, signer ethutil.Signer, result *CollectResult, legs []RouteLeg, nonce uint64, gas int64, gasPrice *big.Int) ([]*CollectResult, uint64) {
+	if len(legs) == 0 {
+		return []*CollectResult{result.skip("no route")}, nonce
+	}
+
+	results := make([]*CollectResult, len(legs))
+	for i, leg := range legs {
+		legResult := result
+		if i > 0 {
+			legResult = newCollectResult(result.Address, result.Token, result.BalanceBefore)
+		}
+		legResult.To = leg.To
+		results[i] = legResult
+
+		txId, err := tokenutil.Transfer(client, signer, result.Token, leg.To, leg.Amount, nonce, gas, gasPrice)
+		if err != nil {
+			ethutil.LogWithTime(fmt.Sprintf("send %s collect tx to %s err: %s,skip...", result.Address, leg.To, err.Error()))
+			legResult.fail(err)
+			continue
+		}
+		legResult.sent(txId, leg.Amount)
+		nonce++
+	}
+
+	return results, nonce
+}
+
+//等待已发送交易的回执并更新状态,超时的交易记为失败
+func confirmResults(client *ethclient.Client, timeoutSeconds int64, results []*CollectResult) {
+	for _, result := range results {
+		if result.Status == StatusSent {
+			_, err := ethutil.WaitTxSuccess(client, result.TxHash, fmt.Sprintf("income %s from %s to %s", result.Collected.String(), result.Address, result.To), timeoutSeconds, ethutil.GetContractAbi(tokenutil.ERC20Abi))
+			result.confirm(err)
+		}
+	}
+}
+
+//按路由规则归集原生币,除最后一个去向外按路由数量转账,最后一个去向归集剩余的全部余额
+//先从余额中扣除所有去向的预估手续费再路由,手续费按路由比例由各去向分摊
+func sweepETHLegs(client *ethclient.Client, chainId *big.Int, signer ethutil.Signer, collectParams *CollectTokenParams, result *CollectResult, opts *SweepOptions) []*CollectResult {
+	//之前的归集交易会消耗手续费,按当前余额计算去向
+	balance := ethutil.GetBalance(client, result.Address)
+	legs := routeLegs(collectParams, result.Address, "", balance)
+	if len(legs) == 0 {
+		return []*CollectResult{result.skip("no route")}
+	}
+	if len(legs) > 1 {
+		gasPrice, err := sweepGasPrice(client, opts)
+		if err != nil {
+			return []*CollectResult{result.fail(err)}
+		}
+		routable := big.NewInt(0).Sub(balance, legsGasFee(legs, int64(params.TxGas), gasPrice))
+		if routable.Sign() <= 0 {
+			return []*CollectResult{result.skip(ErrSweepBalanceTooLow.Error())}
+		}
+		legs = routeLegs(collectParams, result.Address, "", routable)
+	}
+
+	//非最后一个去向的交易连续发送,nonce在本地递增,不等待上一笔上链
+	nonce := ethutil.GetNextNonce(client, result.Address)
+	results := make([]*CollectResult, 0, len(legs))
+	for i, leg := range legs[:len(legs)-1] {
+		legResult := result
+		if i > 0 {
+			legResult = newCollectResult(result.Address, "", result.BalanceBefore)
+		}
+		legResult.To = leg.To
+		results = append(results, legResult)
+
+		txId, err := sendETH(client, chainId, signer, leg.To, leg.Amount, nonce, opts)
+		if err != nil {
+			ethutil.LogWithTime(fmt.Sprintf("send %s eth to %s err: %s,skip...", result.Address, leg.To, err.Error()))
+			legResult.fail(err)
+			continue
+		}
+		legResult.sent(txId, leg.Amount)
+		nonce++
+	}
+	confirmResults(client, collectParams.txTimeoutSeconds(), results)
+
+	lastResult := result
+	if len(legs) > 1 {
+		lastResult = newCollectResult(result.Address, "", result.BalanceBefore)
+	}
+	lastResult.To = legs[len(legs)-1].To
+	results = append(results, lastResult)
+
+	txId, amount, err := SweepETH(client, chainId, signer, lastResult.To, opts)
+	if err == ErrSweepBalanceTooLow {
+		lastResult.skip(err.Error())
+	} else if err != nil {
+		ethutil.LogWithTime(fmt.Sprintf("%s sweep err: %s,skip...", result.Address, err.Error()))
+		lastResult.fail(err)
+	} else {
+		lastResult.sent(txId, amount)
+	}
+
+	return results
+}
@@ -0,0 +1,195 @@
+package collectutil
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/warrior21st/blockchain-utils/ethutil"
+)
+
+func TestSplitRouterRoute(t *testing.T) {
+	destinations := []string{"0xa", "0xb", "0xc"}
+
+	tests := []struct {
+		name     string
+		percents []float64
+		amount   int64
+		want     []RouteLeg
+	}{
+		{
+			name:     "even split",
+			percents: []float64{50, 50, 0},
+			amount:   1000,
+			want:     []RouteLeg{{"0xa", big.NewInt(500)}, {"0xb", big.NewInt(500)}},
+		},
+		{
+			name:     "last gets remainder",
+			percents: []float64{33.33, 33.33, 33.34},
+			amount:   100,
+			want:     []RouteLeg{{"0xa", big.NewInt(33)}, {"0xb", big.NewInt(33)}, {"0xc", big.NewInt(34)}},
+		},
+		{
+			name:     "basis points",
+			percents: []float64{0.01, 99.98, 0.01},
+			amount:   1000000,
+			want:     []RouteLeg{{"0xa", big.NewInt(100)}, {"0xb", big.NewInt(999800)}, {"0xc", big.NewInt(100)}},
+		},
+		{
+			name:     "zero legs dropped",
+			percents: []float64{10, 10, 80},
+			amount:   5,
+			want:     []RouteLeg{{"0xc", big.NewInt(5)}},
+		},
+		{
+			name:     "all to last",
+			percents: []float64{0, 0, 100},
+			amount:   7,
+			want:     []RouteLeg{{"0xc", big.NewInt(7)}},
+		},
+		{
+			name:     "zero amount",
+			percents: []float64{50, 25, 25},
+			amount:   0,
+			want:     []RouteLeg{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount := big.NewInt(tt.amount)
+			legs := NewSplitRouter(destinations, tt.percents).Route("0x1", "", "", amount)
+			if amount.Int64() != tt.amount {
+				t.Fatalf("amount modified to %s", amount)
+			}
+			if len(legs) != len(tt.want) {
+				t.Fatalf("legs = %v, want %v", legs, tt.want)
+			}
+			total := big.NewInt(0)
+			for i := range legs {
+				if legs[i].To != tt.want[i].To || legs[i].Amount.Cmp(tt.want[i].Amount) != 0 {
+					t.Errorf("leg %d = %s %s, want %s %s", i, legs[i].To, legs[i].Amount, tt.want[i].To, tt.want[i].Amount)
+				}
+				total.Add(total, legs[i].Amount)
+			}
+			if total.Cmp(amount) != 0 {
+				t.Errorf("total = %s, want %s", total, amount)
+			}
+		})
+	}
+}
+
+func TestNewSplitRouterInvalid(t *testing.T) {
+	tests := []struct {
+		name         string
+		destinations []string
+		percents     []float64
+	}{
+		{"empty", nil, nil},
+		{"length mismatch", []string{"0xa", "0xb"}, []float64{100}},
+		{"sum less than 100", []string{"0xa", "0xb"}, []float64{50, 40}},
+		{"sum greater than 100", []string{"0xa", "0xb"}, []float64{60, 50}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic")
+				}
+			}()
+			NewSplitRouter(tt.destinations, tt.percents)
+		})
+	}
+}
+
+//只在查询回执时出块的模拟节点,nonce只按已上链的交易计算
+type legsTestNode struct {
+	chainId *big.Int
+	balance *big.Int
+	nonce   uint64
+	pending []*types.Transaction
+	sent    []*types.Transaction
+}
+
+func (node *legsTestNode) GetBalance(addr common.Address, block string) *hexutil.Big {
+	return (*hexutil.Big)(node.balance)
+}
+
+func (node *legsTestNode) GetTransactionCount(addr common.Address, block string) hexutil.Uint64 {
+	return hexutil.Uint64(node.nonce)
+}
+
+func (node *legsTestNode) EstimateGas(args map[string]interface{}) hexutil.Uint64 {
+	return hexutil.Uint64(params.TxGas)
+}
+
+func (node *legsTestNode) SendRawTransaction(data hexutil.Bytes) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(data); err != nil {
+		return common.Hash{}, err
+	}
+	if want := node.nonce + uint64(len(node.pending)); tx.Nonce() != want {
+		return common.Hash{}, fmt.Errorf("nonce %d not equals to %d", tx.Nonce(), want)
+	}
+	node.pending = append(node.pending, tx)
+	node.sent = append(node.sent, tx)
+	return tx.Hash(), nil
+}
+
+func (node *legsTestNode) GetTransactionReceipt(hash common.Hash) *types.Receipt {
+	for _, tx := range node.pending {
+		node.balance.Sub(node.balance, tx.Cost())
+		node.nonce++
+	}
+	node.pending = nil
+	return &types.Receipt{Status: types.ReceiptStatusSuccessful, TxHash: hash, GasUsed: params.TxGas, Logs: []*types.Log{}, BlockNumber: big.NewInt(1)}
+}
+
+func TestSweepETHLegs(t *testing.T) {
+	node := &legsTestNode{chainId: big.NewInt(1337), balance: big.NewInt(1e18)}
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", node); err != nil {
+		t.Fatal(err)
+	}
+	client := ethclient.NewClient(rpc.DialInProc(server))
+	defer client.Close()
+
+	signer := ethutil.NewPrivateKeySignerFromHex("ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80")
+	account := strings.ToLower(signer.Address().Hex())
+	destinations := []string{"0x000000000000000000000000000000000000000a", "0x000000000000000000000000000000000000000b", "0x000000000000000000000000000000000000000c"}
+	collectParams := &CollectTokenParams{Router: NewSplitRouter(destinations, []float64{30, 30, 40})}
+	gasPrice := big.NewInt(1e9)
+
+	results := sweepETHLegs(client, node.chainId, signer, collectParams, newCollectResult(account, "", node.balance), &SweepOptions{GasPrice: gasPrice})
+	if len(results) != 3 {
+		t.Fatalf("results = %d, want 3", len(results))
+	}
+	confirmResults(client, collectParams.txTimeoutSeconds(), results)
+	for i, result := range results {
+		if result.Status != StatusSuccess {
+			t.Errorf("leg %d status = %s, err: %s", i, result.Status, result.Error)
+		}
+		if result.To != destinations[i] {
+			t.Errorf("leg %d to = %s, want %s", i, result.To, destinations[i])
+		}
+	}
+	if len(node.sent) != 3 {
+		t.Fatalf("sent = %d, want 3", len(node.sent))
+	}
+	for i, tx := range node.sent {
+		if tx.Nonce() != uint64(i) {
+			t.Errorf("tx %d nonce = %d, want %d", i, tx.Nonce(), i)
+		}
+	}
+	if node.balance.Sign() != 0 {
+		t.Errorf("balance after sweep = %s, want 0", node.balance)
+	}
+}
@@ -0,0 +1,218 @@
+package collectutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/warrior21st/blockchain-utils/ethutil"
+)
+
+//OP Stack链上的GasPriceOracle预编译合约地址
+const OptimismGasPriceOracle = "0x420000000000000000000000000000000000000F"
+
+const optimismGasPriceOracleAbi = `[{"inputs":[{"internalType":"bytes","name":"_data","type":"bytes"}],"name":"getL1Fee","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+
+//账户余额不足以支付手续费和保留数量
+var ErrSweepBalanceTooLow = errors.New("balance less than or equals to gas fee and reserve")
+
+//计算rollup交易额外收取的L1数据费,unsignedTx为未签名交易
+type L1FeeOracle func(client *ethclient.Client, unsignedTx *types.Transaction) (*big.Int, error)
+
+type SweepOptions struct {
+	//legacy交易的gasPrice,为nil时使用节点建议的gasPrice
+	GasPrice *big.Int
+	//是否发送EIP-1559交易
+	DynamicFee bool
+	//EIP-1559交易的maxFeePerGas,为nil时使用2*baseFee+建议的tip;tip等于feeCap,实际手续费精确为gas*feeCap
+	GasFeeCap *big.Int
+	//账户中保留的原生币数量
+	Reserve *big.Int
+	//rollup链的L1数据费计算
+	L1FeeOracle L1FeeOracle
+}
+
+//通过GasPriceOracle.getL1Fee计算OP Stack链的L1数据费
+func OptimismL1FeeOracle(gasPriceOracle string) L1FeeOracle {
+	return func(client *ethclient.Client, unsignedTx *types.Transaction) (*big.Int, error) {
+		txData, err := unsignedTx.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+
+		oracleAbi := ethutil.GetContractAbi(optimismGasPriceOracleAbi)
+		callData, err := oracleAbi.Pack("getL1Fee", txData)
+		if err != nil {
+			return nil, err
+		}
+
+		oracleAddr := common.HexToAddress(gasPriceOracle)
+		result, err := client.CallContract(context.Background(), ethereum.CallMsg{
+			To:   &oracleAddr,
+			Data: callData,
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return big.NewInt(0).SetBytes(result), nil
+	}
+}
+
+//将账户的全部原生币(扣除手续费和保留数量)转到to,gasPrice等于feeCap使交易后余额精确为0或Reserve
+func SweepETH(client *ethclient.Client, chainId *big.Int, signer ethutil.Signer, to string, opts *SweepOptions) (string, *big.Int, error) {
+	if opts == nil {
+		opts = &SweepOptions{}
+	}
+	from := strings.ToLower(signer.Address().Hex())
+	toAddr := common.HexToAddress(to)
+
+	available := ethutil.GetBalance(client, from)
+	if opts.Reserve != nil {
+		available = big.NewInt(0).Sub(available, opts.Reserve)
+	}
+	if available.Sign() <= 0 {
+		return "", nil, ErrSweepBalanceTooLow
+	}
+
+	gas, err := client.EstimateGas(context.Background(), ethereum.CallMsg{
+		From:  common.HexToAddress(from),
+		To:    &toAddr,
+		Value: available,
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("estimate gas err: %s", err.Error())
+	}
+
+	gasPrice, err := sweepGasPrice(client, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	gasFee := big.NewInt(0).Mul(big.NewInt(int64(gas)), gasPrice)
+
+	nonce := ethutil.GetNextNonce(client, from)
+	newTx := func(amount *big.Int) *types.Transaction {
+		if opts.DynamicFee {
+			return ethutil.NewDynamicFeeTx(chainId, nonce, to, amount, gas, gasPrice, gasPrice, nil)
+		}
+		return ethutil.NewTx(nonce, to, amount, gas, gasPrice, nil)
+	}
+
+	amount := big.NewInt(0).Sub(available, gasFee)
+	if opts.L1FeeOracle != nil {
+		//L1数据费与交易长度有关,金额变化可能导致长度变化,重新计算直到稳定
+		l1Fee := big.NewInt(0)
+		for i := 0; i < 3; i++ {
+			fee, err := opts.L1FeeOracle(client, newTx(amount))
+			if err != nil {
+				return "", nil, fmt.Errorf("get l1 fee err: %s", err.Error())
+			}
+			if fee.Cmp(l1Fee) <= 0 {
+				break
+			}
+			l1Fee = fee
+			amount = big.NewInt(0).Sub(big.NewInt(0).Sub(available, gasFee), l1Fee)
+		}
+	}
+	if amount.Sign() <= 0 {
+		return "", nil, ErrSweepBalanceTooLow
+	}
+
+	signedTx, err := ethutil.SignTxBySigner(signer, newTx(amount), chainId)
+	if err != nil {
+		return "", nil, err
+	}
+	err = ethutil.SendRawTx(client, signedTx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return ethutil.GetRawTxHash(signedTx), amount, nil
+}
+
+//按SweepOptions的手续费设置转账固定数量的原生币
+func sendETH(client *ethclient.Client, chainId *big.Int, signer ethutil.Signer, to string, amount *big.Int, nonce uint64, opts *SweepOptions) (string, error) {
+	from := strings.ToLower(signer.Address().Hex())
+	toAddr := common.HexToAddress(to)
+	gas, err := client.EstimateGas(context.Background(), ethereum.CallMsg{
+		From:  common.HexToAddress(from),
+		To:    &toAddr,
+		Value: amount,
+	})
+	if err != nil {
+		return "", fmt.Errorf("estimate gas err: %s", err.Error())
+	}
+
+	gasPrice, err := sweepGasPrice(client, opts)
+	if err != nil {
+		return "", err
+	}
+
+	var tx *types.Transaction
+	if opts.DynamicFee {
+		tx = ethutil.NewDynamicFeeTx(chainId, nonce, to, amount, gas, gasPrice, gasPrice, nil)
+	} else {
+		tx = ethutil.NewTx(nonce, to, amount, gas, gasPrice, nil)
+	}
+	signedTx, err := ethutil.SignTxBySigner(signer, tx, chainId)
+	if err != nil {
+		return "", err
+	}
+	err = ethutil.SendRawTx(client, signedTx)
+	if err != nil {
+		return "", err
+	}
+
+	return ethutil.GetRawTxHash(signedTx), nil
+}
+
+//每单位gas的手续费,legacy交易为gasPrice,EIP-1559交易为feeCap(tip与feeCap相同,实际按feeCap收取)
+//feeCap为2*baseFee+建议的tip,baseFee上涨时交易仍可上链
+func sweepGasPrice(client *ethclient.Client, opts *SweepOptions) (*big.Int, error) {
+	if !opts.DynamicFee {
+		if opts.GasPrice != nil {
+			return opts.GasPrice, nil
+		}
+		return client.SuggestGasPrice(context.Background())
+	}
+
+	if opts.GasFeeCap != nil {
+		return opts.GasFeeCap, nil
+	}
+	head, err := client.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if head.BaseFee == nil {
+		return nil, errors.New("chain not support EIP-1559")
+	}
+	tip, err := client.SuggestGasTipCap(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return big.NewInt(0).Add(big.NewInt(0).Mul(head.BaseFee, big.NewInt(2)), tip), nil
+}
+
+//根据归集参数生成原生币归集选项
+func sweepOptions(collectParams *CollectTokenParams) *SweepOptions {
+	opts := &SweepOptions{}
+	if collectParams.Sweep != nil {
+		*opts = *collectParams.Sweep
+	}
+	if opts.GasPrice == nil && collectParams.GasPriceGwei > 0 {
+		opts.GasPrice = big.NewInt(int64(collectParams.GasPriceGwei * params.GWei))
+	}
+	if opts.GasFeeCap == nil && opts.DynamicFee && collectParams.GasPriceGwei > 0 {
+		opts.GasFeeCap = big.NewInt(int64(collectParams.GasPriceGwei * params.GWei))
+	}
+
+	return opts
+}
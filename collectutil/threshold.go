@@ -0,0 +1,98 @@
+package collectutil
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/warrior21st/blockchain-utils/ethutil"
+)
+
+//token价格来源
+type PriceSource interface {
+	//返回amount数量(最小单位)的token价值多少原生币(wei)
+	TokenValueInWei(token string, amount *big.Int) (*big.Int, error)
+}
+
+//固定价格的价格来源
+type FixedPriceSource struct {
+	//1个token价值的原生币数量,key为token地址
+	Prices map[string]decimal.Decimal
+	//token精度,key为token地址
+	Decimals map[string]int32
+}
+
+func (s *FixedPriceSource) TokenValueInWei(token string, amount *big.Int) (*big.Int, error) {
+	price, ok := lookupPrice(s.Prices, token)
+	if !ok {
+		return nil, fmt.Errorf("price of %s not found", token)
+	}
+	decimals, ok := lookupDecimals(s.Decimals, token)
+	if !ok {
+		return nil, fmt.Errorf("decimals of %s not found", token)
+	}
+
+	return ethutil.ToWei(ethutil.FromWeiWithDecimals(amount, decimals).Mul(price)), nil
+}
+
+//判断是否跳过归集,返回跳过原因
+func skipReason(collectParams *CollectTokenParams, token string, balance *big.Int, gasFee *big.Int) string {
+	minAmount := collectParams.MinAmount
+	if m, ok := lookupAmount(collectParams.MinAmounts, token); ok {
+		minAmount = m
+	}
+	if minAmount != nil && balance.Cmp(minAmount) < 0 {
+		return fmt.Sprintf("balance %s less than min amount %s", balance.String(), minAmount.String())
+	}
+
+	if collectParams.PriceSource == nil || collectParams.MinFeeMultiple <= 0 || gasFee == nil {
+		return ""
+	}
+	value, err := collectParams.PriceSource.TokenValueInWei(token, balance)
+	if err != nil {
+		ethutil.LogWithTime(fmt.Sprintf("get %s value err: %s,not skip...", token, err.Error()))
+		return ""
+	}
+	minValue := decimal.NewFromBigInt(gasFee, 0).Mul(decimal.NewFromFloat(collectParams.MinFeeMultiple)).BigInt()
+	if value.Cmp(minValue) < 0 {
+		return fmt.Sprintf("value %s less than %v times of gas fee %s", ethutil.FromWei(value), collectParams.MinFeeMultiple, ethutil.FromWei(gasFee))
+	}
+
+	return ""
+}
+
+//打印跳过的账户数量
+func logSkippedResults(results []*CollectResult) {
+	skipped := FilterCollectResults(results, StatusSkipped)
+	if len(skipped) > 0 {
+		ethutil.LogWithTime(fmt.Sprintf("skipped accounts: %d", len(skipped)))
+	}
+}
+
+func lookupAmount(m map[string]*big.Int, token string) (*big.Int, bool) {
+	for k, v := range m {
+		if strings.EqualFold(k, token) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func lookupPrice(m map[string]decimal.Decimal, token string) (decimal.Decimal, bool) {
+	for k, v := range m {
+		if strings.EqualFold(k, token) {
+			return v, true
+		}
+	}
+	return decimal.Zero, false
+}
+
+func lookupDecimals(m map[string]int32, token string) (int32, bool) {
+	for k, v := range m {
+		if strings.EqualFold(k, token) {
+			return v, true
+		}
+	}
+	return 0, false
+}
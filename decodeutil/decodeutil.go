@@ -0,0 +1,373 @@
+package decodeutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/warrior21st/blockchain-utils/airdroputil"
+	"github.com/warrior21st/blockchain-utils/ethutil"
+	"github.com/warrior21st/blockchain-utils/tokenutil"
+	"github.com/warrior21st/go-utils/commonutil"
+)
+
+//来自4字节选择器数据库的方法
+const SourceSelectorDB = "4byte"
+
+var ErrUnknownMethod = errors.New("unknown method")
+
+type DecodedArg struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+type DecodedCall struct {
+	Selector  string        `json:"selector"`
+	Method    string        `json:"method"`
+	Signature string        `json:"signature"`
+	Source    string        `json:"source"`
+	Args      []*DecodedArg `json:"args"`
+}
+
+type DecodedTx struct {
+	Hash      string       `json:"hash"`
+	Type      uint8        `json:"type"`
+	ChainID   *big.Int     `json:"chainId"`
+	From      string       `json:"from"`
+	To        string       `json:"to"`
+	Nonce     uint64       `json:"nonce"`
+	Value     *big.Int     `json:"value"`
+	Gas       uint64       `json:"gas"`
+	GasPrice  *big.Int     `json:"gasPrice"`
+	GasTipCap *big.Int     `json:"gasTipCap"`
+	GasFeeCap *big.Int     `json:"gasFeeCap"`
+	Data      string       `json:"data"`
+	Call      *DecodedCall `json:"call,omitempty"`
+	//无法识别方法时的原因
+	DecodeError string `json:"decodeError,omitempty"`
+}
+
+type namedAbi struct {
+	name string
+	abi  *abi.ABI
+}
+
+//交易calldata解码器,按注册顺序匹配ABI,未匹配时查询4字节选择器数据库
+type Decoder struct {
+	abis      []*namedAbi
+	selectors map[string][]string
+	lock      sync.RWMutex
+}
+
+//创建已注册ERC20和Airdrop ABI的解码器
+func NewDecoder() *Decoder {
+	d := &Decoder{selectors: make(map[string][]string)}
+	d.RegisterAbi("ERC20", tokenutil.ERC20Abi)
+	d.RegisterAbi("Airdrop", airdroputil.AirdropAbi)
+
+	return d
+}
+
+func (d *Decoder) RegisterAbi(name string, abiJson string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.abis = append(d.abis, &namedAbi{name: name, abi: ethutil.GetContractAbi(abiJson)})
+}
+
+//添加方法签名到选择器数据库,如 transfer(address,uint256)
+func (d *Decoder) AddSignature(signature string) error {
+	method, err := parseSignature(signature)
+	if err != nil {
+		return err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	selector := hexutil.Encode(method.ID)
+	for _, s := range d.selectors[selector] {
+		if s == method.Sig {
+			return nil
+		}
+	}
+	d.selectors[selector] = append(d.selectors[selector], method.Sig)
+
+	return nil
+}
+
+//读取每行为 选择器 签名 的数据库文件,选择器与签名之间用逗号、空格或tab分隔,返回读取的签名数量
+func (d *Decoder) LoadSelectorFile(filePath string) int {
+	lines := strings.Split(commonutil.ReadFile(filePath), "\n")
+	count := 0
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if len(line) < 11 || !strings.HasPrefix(line, "0x") {
+			panic(fmt.Errorf("line %d invalid selector", i+1))
+		}
+		selector := strings.ToLower(line[:10])
+		signature := strings.TrimSpace(strings.TrimLeft(line[10:], ", \t"))
+		method, err := parseSignature(signature)
+		if err != nil {
+			panic(fmt.Errorf("line %d parse signature err: %s", i+1, err.Error()))
+		}
+		if hexutil.Encode(method.ID) != selector {
+			ethutil.LogWithTime(fmt.Sprintf("line %d selector %s not match signature %s,skip...", i+1, selector, signature))
+			continue
+		}
+		d.AddSignature(signature)
+		count++
+	}
+
+	return count
+}
+
+//解码calldata
+func (d *Decoder) DecodeCalldata(data []byte) (*DecodedCall, error) {
+	if len(data) < 4 {
+		return nil, errors.New("calldata shorter than 4 bytes")
+	}
+
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	for _, a := range d.abis {
+		method, err := a.abi.MethodById(data[:4])
+		if err != nil {
+			continue
+		}
+		call, err := decodeMethod(method, data)
+		if err != nil {
+			continue
+		}
+		call.Source = a.name
+		return call, nil
+	}
+
+	//同一选择器可能有多个签名,优先使用重新编码后与原数据一致的签名
+	var loose *DecodedCall
+	for _, signature := range d.selectors[hexutil.Encode(data[:4])] {
+		method, err := parseSignature(signature)
+		if err != nil {
+			continue
+		}
+		call, err := decodeMethod(method, data)
+		if err != nil {
+			continue
+		}
+		call.Source = SourceSelectorDB
+		values := make([]interface{}, len(call.Args))
+		for i := range call.Args {
+			values[i] = call.Args[i].Value
+		}
+		packed, err := method.Inputs.Pack(values...)
+		if err == nil && bytes.Equal(packed, data[4:]) {
+			return call, nil
+		}
+		if loose == nil {
+			loose = call
+		}
+	}
+	if loose != nil {
+		return loose, nil
+	}
+
+	return nil, ErrUnknownMethod
+}
+
+//解码已签名交易的原始字节
+func (d *Decoder) DecodeRawTx(raw []byte) (*DecodedTx, error) {
+	tx := new(types.Transaction)
+	err := tx.UnmarshalBinary(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.DecodeTx(tx)
+}
+
+func (d *Decoder) DecodeRawTxHex(rawHex string) (*DecodedTx, error) {
+	if !strings.HasPrefix(rawHex, "0x") {
+		rawHex = "0x" + rawHex
+	}
+	raw, err := hexutil.Decode(rawHex)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.DecodeRawTx(raw)
+}
+
+//查询交易并解码
+func (d *Decoder) DecodeTxByHash(client *ethclient.Client, txId string) (*DecodedTx, error) {
+	tx, _, err := client.TransactionByHash(context.Background(), common.HexToHash(txId))
+	if err != nil {
+		return nil, err
+	}
+
+	return d.DecodeTx(tx)
+}
+
+func (d *Decoder) DecodeTx(tx *types.Transaction) (*DecodedTx, error) {
+	from, err := ethutil.GetTxSender(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DecodedTx{
+		Hash:      tx.Hash().Hex(),
+		Type:      tx.Type(),
+		ChainID:   tx.ChainId(),
+		From:      from.Hex(),
+		Nonce:     tx.Nonce(),
+		Value:     tx.Value(),
+		Gas:       tx.Gas(),
+		GasPrice:  tx.GasPrice(),
+		GasTipCap: tx.GasTipCap(),
+		GasFeeCap: tx.GasFeeCap(),
+		Data:      hexutil.Encode(tx.Data()),
+	}
+	if tx.To() != nil {
+		result.To = tx.To().Hex()
+	}
+	if tx.To() == nil || len(tx.Data()) == 0 {
+		return result, nil
+	}
+
+	result.Call, err = d.DecodeCalldata(tx.Data())
+	if err != nil {
+		result.DecodeError = err.Error()
+	}
+
+	return result, nil
+}
+
+func decodeMethod(method *abi.Method, data []byte) (*DecodedCall, error) {
+	values, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, err
+	}
+
+	call := &DecodedCall{
+		Selector:  hexutil.Encode(method.ID),
+		Method:    method.RawName,
+		Signature: method.Sig,
+		Args:      make([]*DecodedArg, len(values)),
+	}
+	for i, v := range values {
+		name := method.Inputs[i].Name
+		if name == "" {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		call.Args[i] = &DecodedArg{Name: name, Type: method.Inputs[i].Type.String(), Value: v}
+	}
+
+	return call, nil
+}
+
+//解析 name(type1,type2) 格式的方法签名,支持 (type1,type2)[] 形式的tuple
+func parseSignature(signature string) (*abi.Method, error) {
+	signature = strings.ReplaceAll(signature, " ", "")
+	open := strings.Index(signature, "(")
+	if open <= 0 || !strings.HasSuffix(signature, ")") {
+		return nil, fmt.Errorf("invalid signature %s", signature)
+	}
+
+	name := signature[:open]
+	marshalings, err := parseTypeList(signature[open+1 : len(signature)-1])
+	if err != nil {
+		return nil, err
+	}
+	inputs := make(abi.Arguments, len(marshalings))
+	for i, m := range marshalings {
+		typ, err := abi.NewType(m.Type, "", m.Components)
+		if err != nil {
+			return nil, err
+		}
+		inputs[i] = abi.Argument{Type: typ}
+	}
+	method := abi.NewMethod(name, name, abi.Function, "", false, false, inputs, nil)
+
+	return &method, nil
+}
+
+func parseTypeList(s string) ([]abi.ArgumentMarshaling, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses in %s", s)
+	}
+	parts = append(parts, s[start:])
+
+	results := make([]abi.ArgumentMarshaling, len(parts))
+	for i, part := range parts {
+		results[i] = abi.ArgumentMarshaling{Name: fmt.Sprintf("field%d", i), Type: part}
+		if !strings.HasPrefix(part, "(") {
+			continue
+		}
+		end := strings.LastIndex(part, ")")
+		components, err := parseTypeList(part[1:end])
+		if err != nil {
+			return nil, err
+		}
+		results[i].Type = "tuple" + part[end+1:]
+		results[i].Components = components
+	}
+
+	return results, nil
+}
+
+//将解码结果格式化为便于阅读的文本
+func (c *DecodedCall) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("%s [%s] %s\n", c.Signature, c.Source, c.Selector))
+	for _, arg := range c.Args {
+		sb.WriteString(fmt.Sprintf("  %s %s: %s\n", arg.Type, arg.Name, formatValue(arg.Value)))
+	}
+
+	return sb.String()
+}
+
+func formatValue(v interface{}) string {
+	switch value := v.(type) {
+	case []byte:
+		return "0x" + hex.EncodeToString(value)
+	case [32]byte:
+		return "0x" + hex.EncodeToString(value[:])
+	case common.Address:
+		return value.Hex()
+	}
+
+	return fmt.Sprintf("%v", v)
+}
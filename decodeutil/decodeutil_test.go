@@ -0,0 +1,48 @@
+package decodeutil
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestParseSignature(t *testing.T) {
+	tests := []struct {
+		signature string
+		sig       string
+		id        string
+		err       bool
+	}{
+		{signature: "transfer(address,uint256)", sig: "transfer(address,uint256)", id: "0xa9059cbb"},
+		{signature: "approve(address, uint256)", sig: "approve(address,uint256)", id: "0x095ea7b3"},
+		{signature: "balanceOf(address)", sig: "balanceOf(address)", id: "0x70a08231"},
+		{signature: "totalSupply()", sig: "totalSupply()", id: "0x18160ddd"},
+		{signature: "aggregate3((address,bool,bytes)[])", sig: "aggregate3((address,bool,bytes)[])", id: "0x82ad56cb"},
+		{signature: "transfer", err: true},
+		{signature: "(address)", err: true},
+		{signature: "transfer(address", err: true},
+		{signature: "foo((uint256,address)", err: true},
+		{signature: "foo(notatype)", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.signature, func(t *testing.T) {
+			method, err := parseSignature(tt.signature)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got %s", method.Sig)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if method.Sig != tt.sig {
+				t.Errorf("sig = %s, want %s", method.Sig, tt.sig)
+			}
+			if id := hexutil.Encode(method.ID); id != tt.id {
+				t.Errorf("id = %s, want %s", id, tt.id)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package deployutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/warrior21st/blockchain-utils/ethutil"
+)
+
+//确定性部署代理合约,calldata为 salt(32字节) || initCode,各链地址相同
+const DefaultCreate2Factory = "0x4e59b44847b379578588920cA78FbF26c0B4956C"
+
+type DeployOptions struct {
+	//为0时预估gas
+	GasLimit uint64
+	//为nil时使用节点建议的gasPrice
+	GasPrice *big.Int
+	Value    *big.Int
+	//为nil时使用账户的下一个nonce
+	Nonce *uint64
+	//等待回执的超时秒数,0为不超时
+	TimeoutSeconds int64
+	//部署后校验的运行时代码,为空时只校验代码非空
+	ExpectedRuntimeCode []byte
+	//用于解码部署失败时构造函数抛出的自定义错误
+	ContractAbi *abi.ABI
+}
+
+type DeployResult struct {
+	Address common.Address
+	TxHash  string
+	Receipt *types.Receipt
+}
+
+var ErrNoCode = errors.New("no code at deployed address")
+
+//打包部署数据 bytecode || abi编码的构造参数
+func PackDeployData(abiJson string, bytecode string, args ...interface{}) ([]byte, error) {
+	code, err := hexutil.Decode(ensureHexPrefix(strings.TrimSpace(bytecode)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid bytecode: %s", err.Error())
+	}
+	if abiJson == "" {
+		if len(args) > 0 {
+			return nil, errors.New("abi required for constructor args")
+		}
+		return code, nil
+	}
+
+	packedArgs, err := ethutil.GetContractAbi(abiJson).Pack("", args...)
+	if err != nil {
+		return nil, fmt.Errorf("pack constructor args err: %s", err.Error())
+	}
+
+	return append(code, packedArgs...), nil
+}
+
+//预测sender以nonce通过CREATE部署的合约地址
+func PredictCreateAddress(sender string, nonce uint64) common.Address {
+	return crypto.CreateAddress(common.HexToAddress(sender), nonce)
+}
+
+//预测factory以salt通过CREATE2部署initCode的合约地址
+func PredictCreate2Address(factory string, salt common.Hash, initCode []byte) common.Address {
+	return crypto.CreateAddress2(common.HexToAddress(factory), salt, crypto.Keccak256(initCode))
+}
+
+//通过CREATE部署合约,等待回执并校验代码
+func Deploy(client *ethclient.Client, signer ethutil.Signer, abiJson string, bytecode string, opts *DeployOptions, args ...interface{}) (*DeployResult, error) {
+	if opts == nil {
+		opts = &DeployOptions{}
+	}
+	data, err := PackDeployData(abiJson, bytecode, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	from := signer.Address()
+	nonce := nextNonce(client, from, opts)
+	address := PredictCreateAddress(from.Hex(), nonce)
+	ethutil.LogWithTime(fmt.Sprintf("deploying contract from %s nonce %d,predicted address: %s", from.Hex(), nonce, address.Hex()))
+
+	txId, err := sendDeployTx(client, signer, nil, nonce, data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return waitDeployed(client, address, txId, opts)
+}
+
+//通过CREATE2工厂合约确定性部署,factory为空时使用DefaultCreate2Factory,目标地址已有代码时直接返回
+func DeployCreate2(client *ethclient.Client, signer ethutil.Signer, factory string, salt common.Hash, abiJson string, bytecode string, opts *DeployOptions, args ...interface{}) (*DeployResult, error) {
+	if opts == nil {
+		opts = &DeployOptions{}
+	}
+	if factory == "" {
+		factory = DefaultCreate2Factory
+	}
+	initCode, err := PackDeployData(abiJson, bytecode, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	address := PredictCreate2Address(factory, salt, initCode)
+	code, err := client.CodeAt(context.Background(), address, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(code) > 0 {
+		ethutil.LogWithTime(fmt.Sprintf("contract already deployed at %s", address.Hex()))
+		if err := verifyCode(code, opts.ExpectedRuntimeCode); err != nil {
+			return nil, err
+		}
+		return &DeployResult{Address: address}, nil
+	}
+
+	factoryCode, err := client.CodeAt(context.Background(), common.HexToAddress(factory), nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(factoryCode) == 0 {
+		return nil, fmt.Errorf("create2 factory %s not deployed", factory)
+	}
+
+	from := signer.Address()
+	nonce := nextNonce(client, from, opts)
+	ethutil.LogWithTime(fmt.Sprintf("deploying contract by create2 factory %s,salt: %s,predicted address: %s", factory, salt.Hex(), address.Hex()))
+
+	factoryAddr := common.HexToAddress(factory)
+	txId, err := sendDeployTx(client, signer, &factoryAddr, nonce, append(salt.Bytes(), initCode...), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return waitDeployed(client, address, txId, opts)
+}
+
+//校验地址上的合约代码,expectedRuntimeCode为空时只校验代码非空
+func VerifyDeployedCode(client *ethclient.Client, address common.Address, expectedRuntimeCode []byte) error {
+	code, err := client.CodeAt(context.Background(), address, nil)
+	if err != nil {
+		return err
+	}
+
+	return verifyCode(code, expectedRuntimeCode)
+}
+
+func verifyCode(code []byte, expectedRuntimeCode []byte) error {
+	if len(code) == 0 {
+		return ErrNoCode
+	}
+	if len(expectedRuntimeCode) > 0 && !bytes.Equal(code, expectedRuntimeCode) {
+		return errors.New("deployed code not equals to expected runtime code")
+	}
+
+	return nil
+}
+
+func nextNonce(client *ethclient.Client, from common.Address, opts *DeployOptions) uint64 {
+	if opts.Nonce != nil {
+		return *opts.Nonce
+	}
+
+	return ethutil.GetNextNonce(client, from.Hex())
+}
+
+func sendDeployTx(client *ethclient.Client, signer ethutil.Signer, to *common.Address, nonce uint64, data []byte, opts *DeployOptions) (string, error) {
+	chainId := ethutil.GetChainID(client)
+	value := opts.Value
+	if value == nil {
+		value = big.NewInt(0)
+	}
+
+	gasPrice := opts.GasPrice
+	if gasPrice == nil {
+		var err error
+		gasPrice, err = client.SuggestGasPrice(context.Background())
+		if err != nil {
+			return "", err
+		}
+	}
+
+	gas := opts.GasLimit
+	if gas == 0 {
+		var err error
+		gas, err = client.EstimateGas(context.Background(), ethereum.CallMsg{
+			From:  signer.Address(),
+			To:    to,
+			Value: value,
+			Data:  data,
+		})
+		if err != nil {
+			return "", fmt.Errorf("estimate deploy gas err: %s", err.Error())
+		}
+	}
+
+	var tx *types.Transaction
+	if to == nil {
+		tx = ethutil.NewContractCreation(nonce, value, gas, gasPrice, data)
+	} else {
+		tx = ethutil.NewTx(nonce, to.Hex(), value, gas, gasPrice, data)
+	}
+	signedTx, err := ethutil.SignTxBySigner(signer, tx, chainId)
+	if err != nil {
+		return "", err
+	}
+	err = ethutil.SendRawTx(client, signedTx)
+	if err != nil {
+		return "", err
+	}
+
+	txId := ethutil.GetRawTxHash(signedTx)
+	ethutil.LogWithTime(fmt.Sprintf("sended deploy tx: %s...", txId))
+
+	return txId, nil
+}
+
+func waitDeployed(client *ethclient.Client, address common.Address, txId string, opts *DeployOptions) (*DeployResult, error) {
+	receipt, err := ethutil.WaitTxSuccess(client, txId, "deploy", opts.TimeoutSeconds, opts.ContractAbi)
+	if err != nil {
+		return nil, err
+	}
+	if receipt.ContractAddress != (common.Address{}) && receipt.ContractAddress != address {
+		return nil, fmt.Errorf("deployed address %s not equals to predicted %s", receipt.ContractAddress.Hex(), address.Hex())
+	}
+
+	err = VerifyDeployedCode(client, address, opts.ExpectedRuntimeCode)
+	if err != nil {
+		return nil, err
+	}
+	ethutil.LogWithTime(fmt.Sprintf("contract deployed at %s", address.Hex()))
+
+	return &DeployResult{Address: address, TxHash: txId, Receipt: receipt}, nil
+}
+
+func ensureHexPrefix(s string) string {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		s = s[2:]
+	}
+
+	return "0x" + s
+}
@@ -0,0 +1,148 @@
+package ethutil
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const DefaultBatchSize = 100
+
+//按batchSize分批发送json-rpc批量请求,results[i]为第i个请求的结果指针,返回每个请求的错误
+func BatchCall(client *rpc.Client, method string, args [][]interface{}, results []interface{}, batchSize int) []error {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	total := len(args)
+	errs := make([]error, total)
+	for i := 0; i < total; i += batchSize {
+		endIndex := i + batchSize
+		if endIndex > total {
+			endIndex = total
+		}
+
+		elems := make([]rpc.BatchElem, endIndex-i)
+		for j := range elems {
+			elems[j] = rpc.BatchElem{
+				Method: method,
+				Args:   args[i+j],
+				Result: results[i+j],
+			}
+		}
+
+		err := client.BatchCallContext(context.Background(), elems)
+		if err != nil {
+			LogWithTime(fmt.Sprintf("batch %s index %d - %d err: %s", method, i, endIndex-1, err.Error()))
+		}
+		for j := range elems {
+			if err != nil {
+				errs[i+j] = err
+			} else {
+				errs[i+j] = elems[j].Error
+			}
+		}
+	}
+
+	return errs
+}
+
+//批量查询地址的合约代码
+func BatchGetCode(client *rpc.Client, accounts []common.Address, batchSize int) ([][]byte, []error) {
+	return BatchGetCodeAt(client, accounts, LatestBlock, batchSize)
+}
+
+//在指定区块批量查询地址的合约代码
+func BatchGetCodeAt(client *rpc.Client, accounts []common.Address, block BlockTag, batchSize int) ([][]byte, []error) {
+	codes := make([]hexutil.Bytes, len(accounts))
+	args := make([][]interface{}, len(accounts))
+	results := make([]interface{}, len(accounts))
+	for i := range accounts {
+		args[i] = []interface{}{accounts[i], block.rpcArg()}
+		results[i] = &codes[i]
+	}
+
+	errs := BatchCall(client, "eth_getCode", args, results, batchSize)
+	res := make([][]byte, len(accounts))
+	for i := range codes {
+		res[i] = codes[i]
+	}
+
+	return res, errs
+}
+
+//批量查询地址的原生币余额
+func BatchGetBalance(client *rpc.Client, accounts []common.Address, batchSize int) ([]*big.Int, []error) {
+	return BatchGetBalanceAt(client, accounts, LatestBlock, batchSize)
+}
+
+//在指定区块批量查询地址的原生币余额
+func BatchGetBalanceAt(client *rpc.Client, accounts []common.Address, block BlockTag, batchSize int) ([]*big.Int, []error) {
+	balances := make([]hexutil.Big, len(accounts))
+	args := make([][]interface{}, len(accounts))
+	results := make([]interface{}, len(accounts))
+	for i := range accounts {
+		args[i] = []interface{}{accounts[i], block.rpcArg()}
+		results[i] = &balances[i]
+	}
+
+	errs := BatchCall(client, "eth_getBalance", args, results, batchSize)
+	res := make([]*big.Int, len(accounts))
+	for i := range balances {
+		if errs[i] == nil {
+			res[i] = balances[i].ToInt()
+		}
+	}
+
+	return res, errs
+}
+
+//批量查询地址的下一个nonce(包含pending交易)
+func BatchGetNextNonce(client *rpc.Client, accounts []common.Address, batchSize int) ([]uint64, []error) {
+	return BatchGetNonceAt(client, accounts, PendingBlock, batchSize)
+}
+
+//在指定区块批量查询地址的nonce
+func BatchGetNonceAt(client *rpc.Client, accounts []common.Address, block BlockTag, batchSize int) ([]uint64, []error) {
+	nonces := make([]hexutil.Uint64, len(accounts))
+	args := make([][]interface{}, len(accounts))
+	results := make([]interface{}, len(accounts))
+	for i := range accounts {
+		args[i] = []interface{}{accounts[i], block.rpcArg()}
+		results[i] = &nonces[i]
+	}
+
+	errs := BatchCall(client, "eth_getTransactionCount", args, results, batchSize)
+	res := make([]uint64, len(accounts))
+	for i := range nonces {
+		res[i] = uint64(nonces[i])
+	}
+
+	return res, errs
+}
+
+//批量查询交易回执,未上链的交易返回ethereum.NotFound
+func BatchGetTransactionReceipt(client *rpc.Client, txHashes []common.Hash, batchSize int) ([]*types.Receipt, []error) {
+	receipts := make([]*types.Receipt, len(txHashes))
+	args := make([][]interface{}, len(txHashes))
+	results := make([]interface{}, len(txHashes))
+	for i := range txHashes {
+		args[i] = []interface{}{txHashes[i]}
+		results[i] = &receipts[i]
+	}
+
+	errs := BatchCall(client, "eth_getTransactionReceipt", args, results, batchSize)
+	for i := range receipts {
+		if errs[i] == nil && receipts[i] == nil {
+			errs[i] = ethereum.NotFound
+		}
+	}
+
+	return receipts, errs
+}
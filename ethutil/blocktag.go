@@ -0,0 +1,226 @@
+package ethutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	TagLatest    = "latest"
+	TagPending   = "pending"
+	TagSafe      = "safe"
+	TagFinalized = "finalized"
+	TagEarliest  = "earliest"
+)
+
+//查询的区块,可以是区块号、区块hash或标签,零值为latest
+type BlockTag struct {
+	number           *big.Int
+	hash             *common.Hash
+	tag              string
+	requireCanonical bool
+}
+
+var (
+	LatestBlock    = BlockTag{tag: TagLatest}
+	PendingBlock   = BlockTag{tag: TagPending}
+	SafeBlock      = BlockTag{tag: TagSafe}
+	FinalizedBlock = BlockTag{tag: TagFinalized}
+	EarliestBlock  = BlockTag{tag: TagEarliest}
+)
+
+func BlockAt(number uint64) BlockTag {
+	return BlockTag{number: new(big.Int).SetUint64(number)}
+}
+
+//按区块hash查询(EIP-1898),requireCanonical为true时区块不在主链上则报错
+func BlockAtHash(hash common.Hash, requireCanonical bool) BlockTag {
+	return BlockTag{hash: &hash, requireCanonical: requireCanonical}
+}
+
+//解析区块号(10进制或0x开头的16进制)、区块hash或标签
+func ParseBlockTag(s string) (BlockTag, error) {
+	s = strings.TrimSpace(s)
+	switch strings.ToLower(s) {
+	case "", TagLatest:
+		return LatestBlock, nil
+	case TagPending:
+		return PendingBlock, nil
+	case TagSafe:
+		return SafeBlock, nil
+	case TagFinalized:
+		return FinalizedBlock, nil
+	case TagEarliest:
+		return EarliestBlock, nil
+	}
+
+	if len(s) == 66 && strings.HasPrefix(s, "0x") {
+		hash, err := hexutil.Decode(s)
+		if err != nil {
+			return BlockTag{}, fmt.Errorf("invalid block hash %s", s)
+		}
+		return BlockAtHash(common.BytesToHash(hash), false), nil
+	}
+	if strings.HasPrefix(s, "0x") {
+		number, err := hexutil.DecodeUint64(s)
+		if err != nil {
+			return BlockTag{}, fmt.Errorf("invalid block number %s", s)
+		}
+		return BlockAt(number), nil
+	}
+	number, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return BlockTag{}, fmt.Errorf("invalid block tag %s", s)
+	}
+
+	return BlockAt(number), nil
+}
+
+func (b BlockTag) String() string {
+	if b.hash != nil {
+		return b.hash.Hex()
+	}
+	if b.number != nil {
+		return b.number.String()
+	}
+	if b.tag == "" {
+		return TagLatest
+	}
+
+	return b.tag
+}
+
+//json-rpc请求中的区块参数
+func (b BlockTag) rpcArg() interface{} {
+	if b.hash != nil {
+		return map[string]interface{}{
+			"blockHash":        *b.hash,
+			"requireCanonical": b.requireCanonical,
+		}
+	}
+	if b.number != nil {
+		return hexutil.EncodeBig(b.number)
+	}
+	if b.tag == "" {
+		return TagLatest
+	}
+
+	return b.tag
+}
+
+func GetBalanceAt(client *rpc.Client, account string, block BlockTag) (*big.Int, error) {
+	var result hexutil.Big
+	err := client.CallContext(context.Background(), &result, "eth_getBalance", common.HexToAddress(account), block.rpcArg())
+	if err != nil {
+		return nil, err
+	}
+
+	return (*big.Int)(&result), nil
+}
+
+//账户在指定区块的nonce,pending时为下一个可用nonce
+func GetNonceAt(client *rpc.Client, account string, block BlockTag) (uint64, error) {
+	var result hexutil.Uint64
+	err := client.CallContext(context.Background(), &result, "eth_getTransactionCount", common.HexToAddress(account), block.rpcArg())
+
+	return uint64(result), err
+}
+
+func GetCodeAt(client *rpc.Client, account string, block BlockTag) ([]byte, error) {
+	var result hexutil.Bytes
+	err := client.CallContext(context.Background(), &result, "eth_getCode", common.HexToAddress(account), block.rpcArg())
+
+	return result, err
+}
+
+func IsContractAt(client *rpc.Client, account string, block BlockTag) (bool, error) {
+	code, err := GetCodeAt(client, account, block)
+	if err != nil {
+		return false, err
+	}
+
+	return len(code) > 0, nil
+}
+
+//在指定区块执行eth_call
+func CallContractAt(client *rpc.Client, msg ethereum.CallMsg, block BlockTag) ([]byte, error) {
+	var result hexutil.Bytes
+	err := client.CallContext(context.Background(), &result, "eth_call", toCallArg(msg), block.rpcArg())
+
+	return result, err
+}
+
+//区块的号、hash和时间
+type BlockRef struct {
+	Number     uint64
+	Hash       common.Hash
+	ParentHash common.Hash
+	Time       uint64
+}
+
+//查询指定区块,只解析区块号、hash和时间以兼容不同链的区块头格式
+func GetBlockRef(client *rpc.Client, block BlockTag) (*BlockRef, error) {
+	var head *struct {
+		Number     hexutil.Uint64 `json:"number"`
+		Hash       common.Hash    `json:"hash"`
+		ParentHash common.Hash    `json:"parentHash"`
+		Time       hexutil.Uint64 `json:"timestamp"`
+	}
+	var err error
+	if block.hash != nil {
+		err = client.CallContext(context.Background(), &head, "eth_getBlockByHash", *block.hash, false)
+	} else {
+		err = client.CallContext(context.Background(), &head, "eth_getBlockByNumber", block.rpcArg(), false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if head == nil {
+		return nil, ethereum.NotFound
+	}
+
+	return &BlockRef{Number: uint64(head.Number), Hash: head.Hash, ParentHash: head.ParentHash, Time: uint64(head.Time)}, nil
+}
+
+//将标签解析为固定的区块hash,之后使用返回值的多次查询都基于同一区块
+func ResolveBlock(client *rpc.Client, block BlockTag) (BlockTag, *BlockRef, error) {
+	if block.tag == TagPending {
+		return BlockTag{}, nil, errors.New("pending block can not be resolved")
+	}
+	ref, err := GetBlockRef(client, block)
+	if err != nil {
+		return BlockTag{}, nil, err
+	}
+
+	return BlockAtHash(ref.Hash, true), ref, nil
+}
+
+func toCallArg(msg ethereum.CallMsg) interface{} {
+	arg := map[string]interface{}{
+		"from": msg.From,
+		"to":   msg.To,
+	}
+	if len(msg.Data) > 0 {
+		arg["data"] = hexutil.Bytes(msg.Data)
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas != 0 {
+		arg["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
+	}
+
+	return arg
+}
@@ -0,0 +1,70 @@
+package ethutil
+
+import (
+	"testing"
+)
+
+func TestParseBlockTag(t *testing.T) {
+	const hash = "0x88e96d4537bea4d9c05d12549907b32561d3bf31f45aae734cdc119f13406cb6"
+
+	tests := []struct {
+		input string
+		want  string
+		arg   interface{}
+		err   bool
+	}{
+		{input: "", want: "latest", arg: "latest"},
+		{input: "latest", want: "latest", arg: "latest"},
+		{input: " Pending ", want: "pending", arg: "pending"},
+		{input: "SAFE", want: "safe", arg: "safe"},
+		{input: "finalized", want: "finalized", arg: "finalized"},
+		{input: "earliest", want: "earliest", arg: "earliest"},
+		{input: "0", want: "0", arg: "0x0"},
+		{input: "1024", want: "1024", arg: "0x400"},
+		{input: "0x400", want: "1024", arg: "0x400"},
+		{input: "0xffffffffffffffff", want: "18446744073709551615", arg: "0xffffffffffffffff"},
+		{input: hash, want: hash},
+		{input: "0x", err: true},
+		{input: "0x0400", err: true},
+		{input: "0xzz", err: true},
+		{input: "-1", err: true},
+		{input: "18446744073709551616", err: true},
+		{input: "0x88e96d4537bea4d9c05d12549907b32561d3bf31f45aae734cdc119f13406czz", err: true},
+		{input: "head", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			tag, err := ParseBlockTag(tt.input)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got %s", tag.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tag.String() != tt.want {
+				t.Errorf("string = %s, want %s", tag.String(), tt.want)
+			}
+			if tt.arg != nil && tag.rpcArg() != tt.arg {
+				t.Errorf("rpc arg = %v, want %v", tag.rpcArg(), tt.arg)
+			}
+		})
+	}
+}
+
+func TestParseBlockTagHash(t *testing.T) {
+	tag, err := ParseBlockTag("0x88e96d4537bea4d9c05d12549907b32561d3bf31f45aae734cdc119f13406cb6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	arg, ok := tag.rpcArg().(map[string]interface{})
+	if !ok {
+		t.Fatalf("rpc arg = %v, want EIP-1898 object", tag.rpcArg())
+	}
+	if arg["requireCanonical"] != false {
+		t.Errorf("requireCanonical = %v, want false", arg["requireCanonical"])
+	}
+}
@@ -3,7 +3,6 @@ package ethutil
 import (
 	"context"
 	"fmt"
-	"math"
 	"math/big"
 	"strings"
 	"time"
@@ -21,6 +20,7 @@ type TxBaseParams struct {
 	GasPrice *big.Int
 }
 
+//查询失败时一直重试,指定区块查询使用GetNonceAt
 func GetNextNonce(client *ethclient.Client, account string) uint64 {
 	nonce, err := client.NonceAt(context.Background(), common.HexToAddress(account), big.NewInt(rpc.LatestBlockNumber.Int64()))
 	for err != nil {
@@ -35,41 +35,14 @@ func GetNextNonce(client *ethclient.Client, account string) uint64 {
 }
 
 func WaitTxReceipt(client *ethclient.Client, txId string, txDesc string, timeoutSeconds int64) bool {
-	LogWithTime(fmt.Sprintf("querying tx %s receipt...", txId))
-	timeStart := time.Now().Unix()
-	if timeoutSeconds == 0 {
-		timeoutSeconds = math.MaxInt64
-	}
-	for time.Now().Unix()-timeStart < timeoutSeconds {
-		receipt, err := client.TransactionReceipt(context.Background(), common.HexToHash(txId))
-		if receipt == nil {
-			if err == nil || strings.EqualFold(err.Error(), "not found") {
-				LogWithTime(fmt.Sprintf("waiting %s tx %s confirming...", txDesc, txId))
-			} else {
-				LogWithTime(fmt.Sprintf("get %s tx %s receipt err: %s...", txDesc, txId, err.Error()))
-			}
-			time.Sleep(time.Duration(3) * time.Second)
-		} else {
-			if receipt.Status == 1 {
-				break
-			} else {
-				LogWithTime(txDesc + " tx exec failed")
-				return false
-			}
-		}
-	}
-	if time.Now().Unix()-timeStart >= timeoutSeconds {
-		LogWithTime(fmt.Sprintf("get receipt of tx %s time out", txId))
-		return false
-	}
-
-	return true
+	_, err := WaitTxSuccess(client, txId, txDesc, timeoutSeconds)
+	return err == nil
 }
 
 func WaitTxReceiptSuccess(client *ethclient.Client, txId string, txDesc string, timeoutSeconds int64) {
-	b := WaitTxReceipt(client, txId, txDesc, timeoutSeconds)
-	if !b {
-		panic(fmt.Errorf("tx %s exec failed", txId))
+	_, err := WaitTxSuccess(client, txId, txDesc, timeoutSeconds)
+	if err != nil {
+		panic(err)
 	}
 }
 
@@ -84,14 +57,22 @@ func GetChainID(client *ethclient.Client) *big.Int {
 }
 
 func ReadPrivateKeys(filePath string) []string {
+	privs, _ := ReadPrivateKeysWithTags(filePath)
+	return privs
+}
+
+//读取每行为 私钥,分组 的私钥文件,没有分组的行分组为空
+func ReadPrivateKeysWithTags(filePath string) ([]string, []string) {
 	content := commonutil.ReadFile(filePath)
 	privContentArr := strings.Split(content, "\n")
 
 	l := int64(len(privContentArr))
 	results := make([]string, l)
+	tags := make([]string, l)
 
 	for i := int64(0); i < l; i++ {
-		results[i] = strings.Split(privContentArr[i], ",")[0]
+		arr := strings.Split(privContentArr[i], ",")
+		results[i] = arr[0]
 		results[i] = strings.Replace(results[i], "\r", "", -1)
 		results[i] = strings.Replace(results[i], "\t", "", -1)
 
@@ -101,11 +82,15 @@ func ReadPrivateKeys(filePath string) []string {
 		if strings.EqualFold(results[i][0:2], "0x") {
 			results[i] = results[i][2:]
 		}
+		if len(arr) > 1 {
+			tags[i] = strings.TrimSpace(strings.Replace(arr[1], "\r", "", -1))
+		}
 	}
 
-	return results
+	return results, tags
 }
 
+//查询失败时一直重试,指定区块查询使用GetBalanceAt
 func GetBalance(client *ethclient.Client, account string) *big.Int {
 	balance, err := client.BalanceAt(context.Background(), common.HexToAddress(account), big.NewInt(rpc.LatestBlockNumber.Int64()))
 	for err != nil {
@@ -117,6 +102,7 @@ func GetBalance(client *ethclient.Client, account string) *big.Int {
 	return balance
 }
 
+//查询失败时一直重试,指定区块查询使用IsContractAt
 func IsContract(client *ethclient.Client, account string) bool {
 	addr := common.HexToAddress(account)
 	codes, err := client.CodeAt(context.Background(), addr, big.NewInt(-1))
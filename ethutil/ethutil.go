@@ -13,6 +13,7 @@ import (
 	"math/big"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
@@ -275,6 +276,31 @@ func NewContractCreation(nonce uint64, amount *big.Int, gasLimit uint64, gasPric
 	return types.NewContractCreation(nonce, amount, gasLimit, gasPrice, data)
 }
 
+//生成EIP-1559交易
+func NewDynamicFeeTx(chainID *big.Int, nonce uint64, to string, amount *big.Int, gasLimit uint64, gasTipCap *big.Int, gasFeeCap *big.Int, data []byte) *types.Transaction {
+	toAddr := common.HexToAddress(to)
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: gasTipCap,
+		GasFeeCap: gasFeeCap,
+		Gas:       gasLimit,
+		To:        &toAddr,
+		Value:     amount,
+		Data:      data,
+	})
+}
+
+//使用支持所有交易类型的签名器签名交易,等同于NewPrivateKeySigner(prv).SignTx
+func SignTxWithLatestSigner(prv *ecdsa.PrivateKey, tx *types.Transaction, chainID *big.Int) *types.Transaction {
+	signedTx, err := NewPrivateKeySigner(prv).SignTx(tx, chainID)
+	if err != nil {
+		panic(err)
+	}
+
+	return signedTx
+}
+
 //签名交易
 func SignTx(prv *ecdsa.PrivateKey, tx *types.Transaction, chainID *big.Int) *types.Transaction {
 	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), prv)
@@ -463,3 +489,60 @@ func GenNewPrivateKey() string {
 	}
 	return hex.EncodeToString(crypto.FromECDSA(k))
 }
+
+//获取任意类型交易的发送者
+func GetTxSender(tx *types.Transaction) (common.Address, error) {
+	return types.LatestSignerForChainID(tx.ChainId()).Sender(tx)
+}
+
+//使用concurrency个协程并发执行fn(0)...fn(total-1)
+//协程中的panic会被recover,停止分发剩余任务,等待所有协程结束后在调用方协程重新panic
+func RunParallel(total int, concurrency int, fn func(i int)) {
+	if concurrency <= 1 {
+		for i := 0; i < total; i++ {
+			fn(i)
+		}
+		return
+	}
+
+	var panicked interface{}
+	panicOnce := sync.Once{}
+	stop := make(chan struct{})
+	run := func(i int) {
+		defer func() {
+			if r := recover(); r != nil {
+				panicOnce.Do(func() {
+					panicked = r
+					close(stop)
+				})
+			}
+		}()
+		fn(i)
+	}
+
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				run(i)
+			}
+		}()
+	}
+dispatch:
+	for i := 0; i < total; i++ {
+		select {
+		case indexes <- i:
+		case <-stop:
+			break dispatch
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if panicked != nil {
+		panic(panicked)
+	}
+}
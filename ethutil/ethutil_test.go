@@ -0,0 +1,35 @@
+package ethutil
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunParallel(t *testing.T) {
+	for _, concurrency := range []int{0, 1, 4} {
+		counts := make([]int32, 100)
+		RunParallel(len(counts), concurrency, func(i int) {
+			atomic.AddInt32(&counts[i], 1)
+		})
+		for i, count := range counts {
+			if count != 1 {
+				t.Errorf("concurrency %d: fn(%d) called %d times", concurrency, i, count)
+			}
+		}
+	}
+}
+
+func TestRunParallelPanic(t *testing.T) {
+	defer func() {
+		if r := recover(); r != "worker panic" {
+			t.Errorf("recovered %v, want worker panic", r)
+		}
+	}()
+
+	RunParallel(100, 4, func(i int) {
+		if i == 10 {
+			panic("worker panic")
+		}
+	})
+	t.Errorf("expected panic")
+}
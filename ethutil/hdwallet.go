@@ -0,0 +1,215 @@
+package ethutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+	"github.com/warrior21st/go-utils/commonutil"
+)
+
+const (
+	//m/44'/60'/0'/0/i,MetaMask等钱包使用的派生路径
+	DefaultHDPathTemplate = "m/44'/60'/0'/0/%d"
+	//m/44'/60'/i'/0/0,Ledger Live使用的派生路径
+	LedgerLiveHDPathTemplate = "m/44'/60'/%d'/0/0"
+	//m/44'/60'/0'/i,旧版Ledger使用的派生路径
+	LegacyLedgerHDPathTemplate = "m/44'/60'/0'/%d"
+)
+
+//HD钱包派生出的账户
+type HDAccount struct {
+	Path       string
+	Address    string
+	PrivateKey *ecdsa.PrivateKey
+}
+
+//生成助记词,bitSize为128(12个单词)到256(24个单词)之间32的倍数
+func NewMnemonic(bitSize int) string {
+	entropy, err := bip39.NewEntropy(bitSize)
+	if err != nil {
+		panic(err)
+	}
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		panic(err)
+	}
+
+	return mnemonic
+}
+
+//是否是有效的助记词(校验单词和校验和)
+func IsValidMnemonic(mnemonic string) bool {
+	return bip39.IsMnemonicValid(normalizeMnemonic(mnemonic))
+}
+
+//助记词和密码(可为空)转换为种子
+func MnemonicToSeed(mnemonic string, passphrase string) []byte {
+	seed, err := bip39.NewSeedWithErrorChecking(normalizeMnemonic(mnemonic), passphrase)
+	if err != nil {
+		panic(err)
+	}
+
+	return seed
+}
+
+//按BIP-32从种子派生path路径的私钥
+func DeriveKeyFromSeed(seed []byte, path string) (*ecdsa.PrivateKey, error) {
+	derivationPath, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, chainCode := sum[:32], sum[32:]
+	if !isValidChildKey(key) {
+		return nil, errors.New("invalid master key")
+	}
+
+	for _, index := range derivationPath {
+		key, chainCode, err = deriveChildKey(key, chainCode, index)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return crypto.ToECDSA(key)
+}
+
+//从助记词派生path路径的私钥
+func DerivePrivateKey(mnemonic string, passphrase string, path string) *ecdsa.PrivateKey {
+	prv, err := DeriveKeyFromSeed(MnemonicToSeed(mnemonic, passphrase), path)
+	if err != nil {
+		panic(err)
+	}
+
+	return prv
+}
+
+//从助记词批量派生count个账户,pathTemplate中的%d依次替换为start...start+count-1,不包含%d时追加/%d
+func DeriveAccounts(mnemonic string, passphrase string, pathTemplate string, start int, count int) []*HDAccount {
+	if !strings.Contains(pathTemplate, "%d") {
+		pathTemplate = strings.TrimRight(pathTemplate, "/") + "/%d"
+	}
+
+	seed := MnemonicToSeed(mnemonic, passphrase)
+	results := make([]*HDAccount, count)
+	for i := 0; i < count; i++ {
+		path := fmt.Sprintf(pathTemplate, start+i)
+		prv, err := DeriveKeyFromSeed(seed, path)
+		if err != nil {
+			panic(fmt.Errorf("derive %s err: %s", path, err.Error()))
+		}
+		results[i] = &HDAccount{
+			Path:       path,
+			Address:    PubkeyToAddress(&prv.PublicKey),
+			PrivateKey: prv,
+		}
+	}
+
+	return results
+}
+
+//从助记词批量派生count个私钥16进制字符串,可直接用于airdrop/collect
+func DerivePrivateKeyHexes(mnemonic string, passphrase string, pathTemplate string, start int, count int) []string {
+	hdAccounts := DeriveAccounts(mnemonic, passphrase, pathTemplate, start, count)
+	results := make([]string, len(hdAccounts))
+	for i := range hdAccounts {
+		results[i] = ECDSAPrivateKeyToHex(hdAccounts[i].PrivateKey)
+	}
+
+	return results
+}
+
+//BIP-32 CKDpriv
+func deriveChildKey(key []byte, chainCode []byte, index uint32) ([]byte, []byte, error) {
+	data := make([]byte, 0, 37)
+	if index >= 0x80000000 {
+		data = append(data, 0)
+		data = append(data, key...)
+	} else {
+		prv, err := crypto.ToECDSA(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = append(data, crypto.CompressPubkey(&prv.PublicKey)...)
+	}
+	indexBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(indexBytes, index)
+	data = append(data, indexBytes...)
+
+	mac := hmac.New(sha512.New, chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(crypto.S256().Params().N) >= 0 {
+		return nil, nil, fmt.Errorf("invalid child key at index %d", index)
+	}
+	child := il.Add(il, new(big.Int).SetBytes(key))
+	child.Mod(child, crypto.S256().Params().N)
+	if child.Sign() == 0 {
+		return nil, nil, fmt.Errorf("invalid child key at index %d", index)
+	}
+
+	return PaddingLeft0(child.Bytes(), 32), sum[32:], nil
+}
+
+func isValidChildKey(key []byte) bool {
+	k := new(big.Int).SetBytes(key)
+	return k.Sign() > 0 && k.Cmp(crypto.S256().Params().N) < 0
+}
+
+func normalizeMnemonic(mnemonic string) string {
+	return strings.Join(strings.Fields(mnemonic), " ")
+}
+
+//使用密码加密助记词,格式与keystore的crypto字段相同
+func EncryptMnemonic(mnemonic string, pwd string) []byte {
+	cryptoJson, err := keystore.EncryptDataV3([]byte(normalizeMnemonic(mnemonic)), []byte(pwd), keystore.StandardScryptN, keystore.StandardScryptP)
+	if err != nil {
+		panic(err)
+	}
+	content, err := json.Marshal(&mnemonicKeystore{Crypto: cryptoJson, Version: 3})
+	if err != nil {
+		panic(err)
+	}
+
+	return content
+}
+
+//解密EncryptMnemonic加密的助记词
+func DecryptMnemonic(keystoreJson []byte, pwd string) string {
+	k := &mnemonicKeystore{}
+	err := json.Unmarshal(keystoreJson, k)
+	if err != nil {
+		panic(err)
+	}
+	plain, err := keystore.DecryptDataV3(k.Crypto, pwd)
+	if err != nil {
+		panic(err)
+	}
+
+	return string(plain)
+}
+
+func DecryptMnemonicKeystoreFile(filePath string, pwd string) string {
+	return DecryptMnemonic(commonutil.ReadFileBytes(filePath), pwd)
+}
+
+type mnemonicKeystore struct {
+	Crypto  keystore.CryptoJSON `json:"crypto"`
+	Version int                 `json:"version"`
+}
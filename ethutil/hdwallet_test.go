@@ -0,0 +1,84 @@
+package ethutil
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestDeriveKeyFromSeed(t *testing.T) {
+	tests := []struct {
+		name    string
+		seed    []byte
+		path    string
+		prv     string
+		address string
+	}{
+		{
+			name:    "BIP-39 test mnemonic",
+			seed:    MnemonicToSeed("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", ""),
+			path:    "m/44'/60'/0'/0/0",
+			prv:     "1ab42cc412b618bdea3a599e3c9bae199ebf030895b039e9db1e30dafb12b727",
+			address: "0x9858effd232b4033e47d90003d41ec34ecaeda94",
+		},
+		{
+			name:    "hardhat test mnemonic index 0",
+			seed:    MnemonicToSeed("test test test test test test test test test test test junk", ""),
+			path:    "m/44'/60'/0'/0/0",
+			prv:     "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80",
+			address: "0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266",
+		},
+		{
+			name:    "hardhat test mnemonic index 1",
+			seed:    MnemonicToSeed("test test test test test test test test test test test junk", ""),
+			path:    "m/44'/60'/0'/0/1",
+			prv:     "59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d",
+			address: "0x70997970c51812dc3a010c7d01b50e0d17dc79c8",
+		},
+		{
+			name: "BIP-32 test vector 1 m/0'",
+			seed: mustDecodeHex("000102030405060708090a0b0c0d0e0f"),
+			path: "m/0'",
+			prv:  "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea",
+		},
+		{
+			name: "BIP-32 test vector 1 m/0'/1/2'",
+			seed: mustDecodeHex("000102030405060708090a0b0c0d0e0f"),
+			path: "m/0'/1/2'",
+			prv:  "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prv, err := DeriveKeyFromSeed(tt.seed, tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ECDSAPrivateKeyToHex(prv); got != tt.prv {
+				t.Errorf("private key = %s, want %s", got, tt.prv)
+			}
+			if tt.address != "" {
+				if got := PubkeyToAddress(&prv.PublicKey); got != tt.address {
+					t.Errorf("address = %s, want %s", got, tt.address)
+				}
+			}
+		})
+	}
+}
+
+func TestDeriveKeyFromSeedInvalidPath(t *testing.T) {
+	seed := MnemonicToSeed("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "")
+	for _, path := range []string{"", "m/44'/60'/x", "m/44'/60'/0'/0/4294967296"} {
+		if _, err := DeriveKeyFromSeed(seed, path); err == nil {
+			t.Errorf("path %q: expected error", path)
+		}
+	}
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
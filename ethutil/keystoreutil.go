@@ -0,0 +1,292 @@
+package ethutil
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/warrior21st/go-utils/commonutil"
+)
+
+//管理一个目录下的keystore文件,文件按Web3 Secret Storage规范命名
+type KeystoreStore struct {
+	Dir string
+	//是否使用light scryptN,scryptP加密
+	Light bool
+
+	//地址(小写)到文件路径的索引,首次导入或查找时扫描目录建立,写入文件后同步更新
+	index map[string]string
+	lock  sync.Mutex
+}
+
+func NewKeystoreStore(dir string, light bool) *KeystoreStore {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		panic(err)
+	}
+
+	return &KeystoreStore{Dir: dir, Light: light}
+}
+
+//Web3 Secret Storage文件名: UTC--<created at UTC ISO8601>--<address hex>
+func KeystoreFileName(address common.Address) string {
+	ts := time.Now().UTC()
+	return fmt.Sprintf("UTC--%s--%s", toISO8601(ts), hex.EncodeToString(address[:]))
+}
+
+func toISO8601(t time.Time) string {
+	var tz string
+	name, offset := t.Zone()
+	if name == "UTC" {
+		tz = "Z"
+	} else {
+		tz = fmt.Sprintf("%03d00", offset/3600)
+	}
+	return fmt.Sprintf("%04d-%02d-%02dT%02d-%02d-%02d.%09d%s",
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), tz)
+}
+
+//导入私钥,返回keystore文件路径,地址已存在时不重复导入,可并发调用
+func (s *KeystoreStore) Import(prv *ecdsa.PrivateKey, pwd string) string {
+	address := crypto.PubkeyToAddress(prv.PublicKey)
+	key := strings.ToLower(address.Hex())
+	if path, ok := s.indexed(key); ok {
+		return path
+	}
+
+	//加密较慢,不持有锁
+	var keyJson []byte
+	if s.Light {
+		keyJson = EncryptPrivLight(prv, pwd)
+	} else {
+		keyJson = EncryptPrivStandard(prv, pwd)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if path, ok := s.index[key]; ok {
+		return path
+	}
+	path := filepath.Join(s.Dir, KeystoreFileName(address))
+	writeKeystoreFile(path, keyJson)
+	s.index[key] = path
+
+	return path
+}
+
+func (s *KeystoreStore) indexed(address string) (string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.index == nil {
+		_, s.index = s.scan()
+	}
+
+	path, ok := s.index[address]
+	return path, ok
+}
+
+//重新扫描目录建立导入索引,目录被外部修改后调用
+func (s *KeystoreStore) Reindex() {
+	_, index := s.scan()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.index = index
+}
+
+//批量导入ReadPrivateKeys读取的私钥,返回导入的地址,整批只扫描一次目录
+func (s *KeystoreStore) ImportHexKeys(privs []string, pwd string) []string {
+	s.Reindex()
+	addrs := make([]string, len(privs))
+	for i := range privs {
+		prv := HexToECDSAPrivateKey(privs[i])
+		s.Import(prv, pwd)
+		addrs[i] = PubkeyToAddress(&prv.PublicKey)
+		LogWithTime(fmt.Sprintf("import keystore progress %d/%d...", i+1, len(privs)))
+	}
+
+	return addrs
+}
+
+//从私钥文件批量导入
+func (s *KeystoreStore) ImportFile(privsFile string, pwd string) []string {
+	return s.ImportHexKeys(ReadPrivateKeys(privsFile), pwd)
+}
+
+//目录下所有keystore文件的地址(小写)和路径
+func (s *KeystoreStore) Accounts() map[string]string {
+	_, accounts := s.scan()
+	return accounts
+}
+
+//目录下所有keystore的地址(小写),按文件名排序
+func (s *KeystoreStore) Addresses() []string {
+	addrs, _ := s.scan()
+	return addrs
+}
+
+func (s *KeystoreStore) scan() ([]string, map[string]string) {
+	files, err := ioutil.ReadDir(s.Dir)
+	if err != nil {
+		panic(err)
+	}
+
+	addrs := make([]string, 0)
+	accounts := make(map[string]string)
+	for _, f := range files {
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") || strings.HasSuffix(f.Name(), ".tmp") {
+			continue
+		}
+		path := filepath.Join(s.Dir, f.Name())
+		address, err := readKeystoreAddress(path)
+		if err != nil {
+			continue
+		}
+		if _, ok := accounts[address]; !ok {
+			addrs = append(addrs, address)
+		}
+		accounts[address] = path
+	}
+
+	return addrs, accounts
+}
+
+//按地址查找keystore文件路径,使用导入索引,索引中没有该地址时重新扫描一次目录
+func (s *KeystoreStore) Find(address string) (string, bool) {
+	key := strings.ToLower(common.HexToAddress(address).Hex())
+	if path, ok := s.indexed(key); ok {
+		return path, ok
+	}
+
+	s.Reindex()
+	return s.indexed(key)
+}
+
+//按地址加载私钥
+func (s *KeystoreStore) Load(address string, pwd string) *ecdsa.PrivateKey {
+	path, ok := s.Find(address)
+	if !ok {
+		panic(fmt.Errorf("keystore of %s not found", address))
+	}
+
+	return DecryptKeystore(commonutil.ReadFileBytes(path), pwd)
+}
+
+//加载目录下所有私钥,顺序与Addresses一致
+func (s *KeystoreStore) LoadAll(pwd string) []*ecdsa.PrivateKey {
+	addrs, accounts := s.scan()
+	results := make([]*ecdsa.PrivateKey, len(addrs))
+	for i := range addrs {
+		results[i] = DecryptKeystore(commonutil.ReadFileBytes(accounts[addrs[i]]), pwd)
+	}
+
+	return results
+}
+
+//导出目录下所有私钥的16进制字符串,顺序与Addresses一致
+func (s *KeystoreStore) ExportHexKeys(pwd string) []string {
+	prvs := s.LoadAll(pwd)
+	results := make([]string, len(prvs))
+	for i := range prvs {
+		results[i] = ECDSAPrivateKeyToHex(prvs[i])
+	}
+
+	return results
+}
+
+//导出所有私钥到文件,每行为 私钥,地址,可被ReadPrivateKeys读取
+func (s *KeystoreStore) ExportToFile(pwd string, filePath string) {
+	prvs := s.LoadAll(pwd)
+	lines := make([]string, len(prvs))
+	for i := range prvs {
+		lines[i] = ECDSAPrivateKeyToHex(prvs[i]) + "," + PubkeyToAddress(&prvs[i].PublicKey)
+	}
+
+	err := ioutil.WriteFile(filePath, []byte(strings.Join(lines, "\n")), 0600)
+	if err != nil {
+		panic(err)
+	}
+}
+
+//修改目录下所有keystore的密码,先全部解密校验旧密码,再将新文件全部写入临时文件,最后逐个重命名替换
+//写入临时文件失败时删除已写入的临时文件,原文件保持旧密码不变
+func (s *KeystoreStore) ChangePassword(oldPwd string, newPwd string) {
+	accounts := s.Accounts()
+	prvs := make(map[string]*ecdsa.PrivateKey)
+	for address, path := range accounts {
+		prvs[address] = DecryptKeystore(commonutil.ReadFileBytes(path), oldPwd)
+	}
+
+	tmpPaths := make([]string, 0, len(accounts))
+	removeTmpFiles := func() {
+		for _, tmpPath := range tmpPaths {
+			os.Remove(tmpPath)
+		}
+	}
+	for address, path := range accounts {
+		var keyJson []byte
+		if s.Light {
+			keyJson = EncryptPrivLight(prvs[address], newPwd)
+		} else {
+			keyJson = EncryptPrivStandard(prvs[address], newPwd)
+		}
+		tmpPath := path + ".tmp"
+		err := ioutil.WriteFile(tmpPath, keyJson, 0600)
+		if err != nil {
+			os.Remove(tmpPath)
+			removeTmpFiles()
+			panic(err)
+		}
+		tmpPaths = append(tmpPaths, tmpPath)
+	}
+
+	for address, path := range accounts {
+		err := os.Rename(path+".tmp", path)
+		if err != nil {
+			panic(err)
+		}
+		LogWithTime(fmt.Sprintf("changed password of %s", address))
+	}
+}
+
+func readKeystoreAddress(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	k := struct {
+		Address string `json:"address"`
+	}{}
+	err = json.Unmarshal(content, &k)
+	if err != nil {
+		return "", err
+	}
+	if !common.IsHexAddress(k.Address) {
+		return "", fmt.Errorf("invalid keystore address: %s", k.Address)
+	}
+
+	return strings.ToLower(common.HexToAddress(k.Address).Hex()), nil
+}
+
+//先写入临时文件再替换,避免写入中断导致keystore损坏
+func writeKeystoreFile(path string, content []byte) {
+	tmpPath := path + ".tmp"
+	err := ioutil.WriteFile(tmpPath, content, 0600)
+	if err != nil {
+		panic(err)
+	}
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,122 @@
+package ethutil
+
+import (
+	"crypto/ecdsa"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func newTestKeystoreStore(t *testing.T, count int, pwd string) (*KeystoreStore, []*ecdsa.PrivateKey) {
+	store := NewKeystoreStore(t.TempDir(), true)
+	prvs := make([]*ecdsa.PrivateKey, count)
+	for i := range prvs {
+		prv, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		prvs[i] = prv
+		store.Import(prv, pwd)
+	}
+
+	return store, prvs
+}
+
+func TestKeystoreStoreFind(t *testing.T) {
+	store, prvs := newTestKeystoreStore(t, 3, "pwd")
+
+	for _, prv := range prvs {
+		address := PubkeyToAddress(&prv.PublicKey)
+		path, ok := store.Find(strings.ToUpper(address[2:]))
+		if !ok {
+			t.Fatalf("%s not found", address)
+		}
+		if !strings.HasSuffix(path, address[2:]) {
+			t.Errorf("%s path = %s", address, path)
+		}
+		if loaded := store.Load(address, "pwd"); loaded.D.Cmp(prv.D) != 0 {
+			t.Errorf("%s loaded wrong key", address)
+		}
+	}
+	if _, ok := store.Find("0x000000000000000000000000000000000000dead"); ok {
+		t.Errorf("found unknown address")
+	}
+
+	//目录被外部写入的keystore在索引中没有时重新扫描目录
+	other, external := newTestKeystoreStore(t, 1, "pwd")
+	address := PubkeyToAddress(&external[0].PublicKey)
+	path, _ := other.Find(address)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(store.Dir, filepath.Base(path)), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Find(address); !ok {
+		t.Errorf("external keystore %s not found", address)
+	}
+}
+
+func TestKeystoreStoreChangePassword(t *testing.T) {
+	store, prvs := newTestKeystoreStore(t, 3, "old")
+
+	store.ChangePassword("old", "new")
+	for _, prv := range prvs {
+		if loaded := store.Load(PubkeyToAddress(&prv.PublicKey), "new"); loaded.D.Cmp(prv.D) != 0 {
+			t.Errorf("%s loaded wrong key", PubkeyToAddress(&prv.PublicKey))
+		}
+	}
+
+	//旧密码错误时不修改任何文件
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected panic for wrong password")
+			}
+		}()
+		store.ChangePassword("old", "other")
+	}()
+	if len(store.LoadAll("new")) != len(prvs) {
+		t.Errorf("keys changed after wrong password")
+	}
+}
+
+func TestKeystoreStoreChangePasswordWriteFailure(t *testing.T) {
+	store, prvs := newTestKeystoreStore(t, 3, "old")
+
+	//其中一个临时文件路径被目录占用,写入失败
+	blocked, _ := store.Find(PubkeyToAddress(&prvs[1].PublicKey))
+	if err := os.Mkdir(blocked+".tmp", 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(blocked+".tmp", "f"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected panic for write failure")
+			}
+		}()
+		store.ChangePassword("old", "new")
+	}()
+
+	//所有keystore仍使用旧密码,没有残留的临时文件
+	if len(store.LoadAll("old")) != len(prvs) {
+		t.Errorf("keys changed after write failure")
+	}
+	files, err := ioutil.ReadDir(store.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), ".tmp") && filepath.Join(store.Dir, f.Name()) != blocked+".tmp" {
+			t.Errorf("tmp file %s left behind", f.Name())
+		}
+	}
+}
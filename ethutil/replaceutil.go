@@ -0,0 +1,274 @@
+package ethutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	//geth/erigon等节点替换交易要求的最低涨幅
+	MinReplaceBumpPercent = 10
+	DefaultReplaceWait    = 60 * time.Second
+)
+
+var (
+	ErrTxReplacedExternally = errors.New("nonce used by a tx not sent by replacement manager")
+	ErrReplaceGasPriceLimit = errors.New("bumped gas price exceeds max gas price")
+)
+
+type ReplaceParams struct {
+	//每次替换前等待交易被打包的时长,为0时使用DefaultReplaceWait
+	Wait time.Duration
+	//每次替换的手续费涨幅百分比,小于MinReplaceBumpPercent时使用MinReplaceBumpPercent
+	BumpPercent int64
+	//gasPrice(或feeCap)上限,达到上限后不再替换只等待,为nil时不限制
+	MaxGasPrice *big.Int
+	//最多替换次数,<=0时不限制
+	MaxReplacements int
+	//为true时以0数量转给自己的交易取消原交易,否则以相同内容加速
+	Cancel bool
+	//查询回执的间隔,为0时为3秒
+	PollInterval time.Duration
+	//为0时不超时
+	Timeout time.Duration
+}
+
+//已打包的交易及其回执,Tx可能是原交易或任一替换交易
+type ReplaceResult struct {
+	Tx           *types.Transaction
+	Receipt      *types.Receipt
+	Replacements int
+	Cancelled    bool
+}
+
+//签名并发送交易,超过等待时长未打包时提高手续费重发相同nonce,直到其中一个版本被打包
+func SendTxWithReplacement(client *ethclient.Client, signer Signer, tx *types.Transaction, chainID *big.Int, paras *ReplaceParams) (*ReplaceResult, error) {
+	signedTx, err := SignTxBySigner(signer, tx, chainID)
+	if err != nil {
+		return nil, err
+	}
+	err = SendRawTx(client, signedTx)
+	if err != nil {
+		return nil, err
+	}
+	LogWithTime(fmt.Sprintf("sended tx %s nonce %d...", signedTx.Hash().Hex(), signedTx.Nonce()))
+
+	return ReplaceUntilMined(client, signer, signedTx, chainID, paras)
+}
+
+//按哈希查询未打包的交易并加速或取消
+func ReplaceStuckTx(client *ethclient.Client, signer Signer, txId string, paras *ReplaceParams) (*ReplaceResult, error) {
+	tx, isPending, err := client.TransactionByHash(context.Background(), common.HexToHash(txId))
+	if err != nil {
+		return nil, err
+	}
+	if !isPending {
+		receipt, err := client.TransactionReceipt(context.Background(), tx.Hash())
+		if err != nil {
+			return nil, err
+		}
+		return &ReplaceResult{Tx: tx, Receipt: receipt}, nil
+	}
+
+	return ReplaceUntilMined(client, signer, tx, tx.ChainId(), paras)
+}
+
+//等待已发送的交易,超时未打包时替换,返回最终被打包的版本
+func ReplaceUntilMined(client *ethclient.Client, signer Signer, sentTx *types.Transaction, chainID *big.Int, paras *ReplaceParams) (*ReplaceResult, error) {
+	if paras == nil {
+		paras = &ReplaceParams{}
+	}
+	wait := paras.Wait
+	if wait <= 0 {
+		wait = DefaultReplaceWait
+	}
+	pollInterval := paras.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = 3 * time.Second
+	}
+
+	from := signer.Address()
+	sent := []*types.Transaction{sentTx}
+	latest := sentTx
+	lastSend := time.Now()
+	start := time.Now()
+	result := &ReplaceResult{}
+	for paras.Timeout <= 0 || time.Since(start) < paras.Timeout {
+		for _, tx := range sent {
+			receipt, err := client.TransactionReceipt(context.Background(), tx.Hash())
+			if err == nil && receipt != nil {
+				result.Tx = tx
+				result.Receipt = receipt
+				result.Cancelled = paras.Cancel && tx.Hash() != sentTx.Hash() && tx.To() != nil && *tx.To() == from
+				LogWithTime(fmt.Sprintf("tx nonce %d mined by %s after %d replacements", tx.Nonce(), tx.Hash().Hex(), result.Replacements))
+				return result, nil
+			}
+		}
+
+		//nonce已被使用但没有我们发送的任何版本的回执
+		nonce, err := client.NonceAt(context.Background(), from, nil)
+		if err == nil && nonce > sentTx.Nonce() {
+			time.Sleep(pollInterval)
+			if minedAny(client, sent) {
+				continue
+			}
+			return nil, ErrTxReplacedExternally
+		}
+
+		canReplace := paras.MaxReplacements <= 0 || result.Replacements < paras.MaxReplacements
+		if canReplace && time.Since(lastSend) >= wait {
+			replacement, err := buildReplacement(client, latest, from, chainID, paras)
+			if err == ErrReplaceGasPriceLimit {
+				LogWithTime(fmt.Sprintf("tx nonce %d reached max gas price,keep waiting...", sentTx.Nonce()))
+				lastSend = time.Now()
+			} else if err != nil {
+				return nil, err
+			} else {
+				signedTx, err := SignTxBySigner(signer, replacement, chainID)
+				if err != nil {
+					return nil, err
+				}
+				err = SendRawTx(client, signedTx)
+				//发送报错时节点仍可能已接收(如请求超时),同样需要查询该版本的回执
+				sent = append(sent, signedTx)
+				if err != nil && !isKnownTxError(err) {
+					LogWithTime(fmt.Sprintf("send replacement of nonce %d err: %s", sentTx.Nonce(), err.Error()))
+				} else {
+					result.Replacements++
+					LogWithTime(fmt.Sprintf("sended replacement tx %s nonce %d gasPrice %s...", signedTx.Hash().Hex(), signedTx.Nonce(), signedTx.GasFeeCap().String()))
+				}
+				//替换被拒绝(如涨幅不足)时下次在此基础上继续提高
+				latest = signedTx
+				lastSend = time.Now()
+			}
+		}
+
+		time.Sleep(pollInterval)
+	}
+
+	return nil, ErrWaitTxTimeout
+}
+
+func minedAny(client *ethclient.Client, txs []*types.Transaction) bool {
+	for _, tx := range txs {
+		receipt, err := client.TransactionReceipt(context.Background(), tx.Hash())
+		if err == nil && receipt != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isKnownTxError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "already known") || strings.Contains(msg, "known transaction")
+}
+
+//按涨幅提高手续费,且不低于当前建议值,取消时改为0数量转给自己
+func buildReplacement(client *ethclient.Client, tx *types.Transaction, from common.Address, chainID *big.Int, paras *ReplaceParams) (*types.Transaction, error) {
+	bump := paras.BumpPercent
+	if bump < MinReplaceBumpPercent {
+		bump = MinReplaceBumpPercent
+	}
+
+	to := tx.To()
+	value := tx.Value()
+	data := tx.Data()
+	gas := tx.Gas()
+	accessList := tx.AccessList()
+	if paras.Cancel {
+		//取消交易为普通转账,access list会使gas超过21000
+		to = &from
+		value = big.NewInt(0)
+		data = nil
+		gas = 21000
+		accessList = nil
+	}
+
+	suggested, err := client.SuggestGasPrice(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	if tx.Type() == types.DynamicFeeTxType {
+		tipCap := bumpFee(tx.GasTipCap(), bump)
+		suggestedTip, err := client.SuggestGasTipCap(context.Background())
+		if err == nil {
+			tipCap = maxBig(tipCap, suggestedTip)
+		}
+		feeCap := maxBig(bumpFee(tx.GasFeeCap(), bump), suggested)
+		if feeCap.Cmp(tipCap) < 0 {
+			feeCap = tipCap
+		}
+		if paras.MaxGasPrice != nil && feeCap.Cmp(paras.MaxGasPrice) > 0 {
+			return nil, ErrReplaceGasPriceLimit
+		}
+
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    chainID,
+			Nonce:      tx.Nonce(),
+			GasTipCap:  tipCap,
+			GasFeeCap:  feeCap,
+			Gas:        gas,
+			To:         to,
+			Value:      value,
+			Data:       data,
+			AccessList: accessList,
+		}), nil
+	}
+
+	gasPrice := maxBig(bumpFee(tx.GasPrice(), bump), suggested)
+	if paras.MaxGasPrice != nil && gasPrice.Cmp(paras.MaxGasPrice) > 0 {
+		return nil, ErrReplaceGasPriceLimit
+	}
+	if tx.Type() == types.AccessListTxType {
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    chainID,
+			Nonce:      tx.Nonce(),
+			GasPrice:   gasPrice,
+			Gas:        gas,
+			To:         to,
+			Value:      value,
+			Data:       data,
+			AccessList: accessList,
+		}), nil
+	}
+
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    tx.Nonce(),
+		GasPrice: gasPrice,
+		Gas:      gas,
+		To:       to,
+		Value:    value,
+		Data:     data,
+	}), nil
+}
+
+//fee * (100 + percent) / 100,向上取整保证达到节点要求的最低涨幅
+func bumpFee(fee *big.Int, percent int64) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(100+percent))
+	bumped.Add(bumped, big.NewInt(99))
+	bumped.Div(bumped, big.NewInt(100))
+	if bumped.Cmp(fee) <= 0 {
+		bumped = new(big.Int).Add(fee, big.NewInt(1))
+	}
+
+	return bumped
+}
+
+func maxBig(a *big.Int, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,245 @@
+package ethutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	RevertKindError   = "error"
+	RevertKindPanic   = "panic"
+	RevertKindCustom  = "custom"
+	RevertKindUnknown = "unknown"
+)
+
+var (
+	//Error(string)
+	ErrorSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+	//Panic(uint256)
+	PanicSelector = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+
+	ErrWaitTxTimeout = errors.New("wait tx receipt timeout")
+)
+
+//solidity内置Panic错误码说明
+var PanicReasons = map[uint64]string{
+	0x00: "generic compiler panic",
+	0x01: "assert failed",
+	0x11: "arithmetic overflow or underflow",
+	0x12: "division or modulo by zero",
+	0x21: "invalid enum value",
+	0x22: "invalid storage byte array encoding",
+	0x31: "pop on empty array",
+	0x32: "array index out of bounds",
+	0x41: "too much memory allocated",
+	0x51: "call to zero-initialized internal function",
+}
+
+//解码后的revert原因
+type RevertReason struct {
+	Kind      string
+	Reason    string
+	ErrorName string
+	ErrorArgs []interface{}
+	PanicCode *big.Int
+	Data      []byte
+}
+
+func (r *RevertReason) String() string {
+	switch r.Kind {
+	case RevertKindError:
+		return r.Reason
+	case RevertKindPanic:
+		return fmt.Sprintf("panic 0x%x: %s", r.PanicCode, r.Reason)
+	case RevertKindCustom:
+		return fmt.Sprintf("%s%v", r.ErrorName, r.ErrorArgs)
+	}
+	if len(r.Data) == 0 {
+		return "reverted without reason"
+	}
+
+	return fmt.Sprintf("unknown revert data %s", hexutil.Encode(r.Data))
+}
+
+//执行失败的交易,Revert为nil表示无法重放获取原因
+type TxFailedError struct {
+	TxHash      string
+	BlockNumber uint64
+	Receipt     *types.Receipt
+	Revert      *RevertReason
+}
+
+func (e *TxFailedError) Error() string {
+	if e.Revert == nil {
+		return fmt.Sprintf("tx %s exec failed", e.TxHash)
+	}
+
+	return fmt.Sprintf("tx %s exec failed: %s", e.TxHash, e.Revert.String())
+}
+
+//解码revert数据,contractAbis用于匹配自定义错误
+func DecodeRevertData(data []byte, contractAbis ...*abi.ABI) *RevertReason {
+	result := &RevertReason{Kind: RevertKindUnknown, Data: data}
+	if len(data) < 4 {
+		return result
+	}
+
+	selector := data[:4]
+	switch {
+	case bytes.Equal(selector, ErrorSelector):
+		reason, err := abi.UnpackRevert(data)
+		if err == nil {
+			result.Kind = RevertKindError
+			result.Reason = reason
+		}
+		return result
+	case bytes.Equal(selector, PanicSelector):
+		if len(data) == 36 {
+			code := new(big.Int).SetBytes(data[4:])
+			result.Kind = RevertKindPanic
+			result.PanicCode = code
+			result.Reason = "unknown panic code"
+			if code.IsUint64() {
+				if reason, ok := PanicReasons[code.Uint64()]; ok {
+					result.Reason = reason
+				}
+			}
+		}
+		return result
+	}
+
+	for _, contractAbi := range contractAbis {
+		if contractAbi == nil {
+			continue
+		}
+		for _, abiErr := range contractAbi.Errors {
+			if !bytes.Equal(abiErr.ID[:4], selector) {
+				continue
+			}
+			args, err := abiErr.Inputs.Unpack(data[4:])
+			if err != nil {
+				continue
+			}
+			result.Kind = RevertKindCustom
+			result.ErrorName = abiErr.Name
+			result.ErrorArgs = args
+			return result
+		}
+	}
+
+	return result
+}
+
+//从eth_call返回的错误中取出revert数据
+func RevertDataFromError(err error) ([]byte, bool) {
+	dataErr, ok := err.(rpc.DataError)
+	if !ok {
+		return nil, false
+	}
+	hexData, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return nil, false
+	}
+	data, decodeErr := hexutil.Decode(hexData)
+	if decodeErr != nil {
+		return nil, false
+	}
+
+	return data, true
+}
+
+//在交易所在区块的父区块状态上通过eth_call重放交易并解码revert原因,同区块内排在前面的交易不会被重放,结果可能与链上执行不一致
+func ReplayTxRevert(client *ethclient.Client, txId string, blockNumber *big.Int, contractAbis ...*abi.ABI) (*RevertReason, error) {
+	tx, _, err := client.TransactionByHash(context.Background(), common.HexToHash(txId))
+	if err != nil {
+		return nil, err
+	}
+	from, err := types.LatestSignerForChainID(tx.ChainId()).Sender(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var parentNumber *big.Int
+	if blockNumber != nil && blockNumber.Sign() > 0 {
+		parentNumber = new(big.Int).Sub(blockNumber, big.NewInt(1))
+	}
+	_, err = client.CallContract(context.Background(), ethereum.CallMsg{
+		From:       from,
+		To:         tx.To(),
+		Gas:        tx.Gas(),
+		Value:      tx.Value(),
+		Data:       tx.Data(),
+		AccessList: tx.AccessList(),
+	}, parentNumber)
+	if err == nil {
+		return nil, errors.New("replayed tx not reverted")
+	}
+	data, ok := RevertDataFromError(err)
+	if !ok {
+		//非revert的错误(如状态缺失、超时)直接返回,避免被当作revert原因
+		if !strings.HasPrefix(err.Error(), "execution reverted") {
+			return nil, err
+		}
+		//无revert数据或节点只返回错误信息
+		if err.Error() == "execution reverted" {
+			return &RevertReason{Kind: RevertKindUnknown}, nil
+		}
+		reason := strings.TrimPrefix(err.Error(), "execution reverted: ")
+		return &RevertReason{Kind: RevertKindError, Reason: reason}, nil
+	}
+
+	return DecodeRevertData(data, contractAbis...), nil
+}
+
+//等待交易回执,交易执行失败时返回带revert原因的*TxFailedError,超时返回ErrWaitTxTimeout
+func WaitTxSuccess(client *ethclient.Client, txId string, txDesc string, timeoutSeconds int64, contractAbis ...*abi.ABI) (*types.Receipt, error) {
+	LogWithTime(fmt.Sprintf("querying tx %s receipt...", txId))
+	timeStart := time.Now().Unix()
+	if timeoutSeconds == 0 {
+		timeoutSeconds = math.MaxInt64
+	}
+	for time.Now().Unix()-timeStart < timeoutSeconds {
+		receipt, err := client.TransactionReceipt(context.Background(), common.HexToHash(txId))
+		if receipt == nil {
+			if err == nil || strings.EqualFold(err.Error(), "not found") {
+				LogWithTime(fmt.Sprintf("waiting %s tx %s confirming...", txDesc, txId))
+			} else {
+				LogWithTime(fmt.Sprintf("get %s tx %s receipt err: %s...", txDesc, txId, err.Error()))
+			}
+			time.Sleep(time.Duration(3) * time.Second)
+			continue
+		}
+		if receipt.Status == types.ReceiptStatusSuccessful {
+			return receipt, nil
+		}
+
+		failedErr := &TxFailedError{TxHash: txId, BlockNumber: receipt.BlockNumber.Uint64(), Receipt: receipt}
+		revert, err := ReplayTxRevert(client, txId, receipt.BlockNumber, contractAbis...)
+		if err != nil {
+			LogWithTime(fmt.Sprintf("replay %s tx %s err: %s", txDesc, txId, err.Error()))
+		} else {
+			failedErr.Revert = revert
+		}
+		LogWithTime(fmt.Sprintf("%s %s", txDesc, failedErr.Error()))
+
+		return receipt, failedErr
+	}
+	LogWithTime(fmt.Sprintf("get receipt of tx %s time out", txId))
+
+	return nil, ErrWaitTxTimeout
+}
@@ -0,0 +1,102 @@
+package ethutil
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestDecodeRevertData(t *testing.T) {
+	customAbi, err := abi.JSON(strings.NewReader(`[{"type":"error","name":"InsufficientBalance","inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		data      string
+		abis      []*abi.ABI
+		kind      string
+		reason    string
+		panicCode int64
+		str       string
+	}{
+		{
+			name:   "Error(string)",
+			data:   "0x08c379a0" + "0000000000000000000000000000000000000000000000000000000000000020" + "000000000000000000000000000000000000000000000000000000000000001a" + "4e6f7420656e6f7567682045746865722070726f76696465642e000000000000",
+			kind:   RevertKindError,
+			reason: "Not enough Ether provided.",
+			str:    "Not enough Ether provided.",
+		},
+		{
+			name:      "Panic(0x11)",
+			data:      "0x4e487b71" + "0000000000000000000000000000000000000000000000000000000000000011",
+			kind:      RevertKindPanic,
+			reason:    "arithmetic overflow or underflow",
+			panicCode: 0x11,
+			str:       "panic 0x11: arithmetic overflow or underflow",
+		},
+		{
+			name:      "Panic(0x01)",
+			data:      "0x4e487b71" + "0000000000000000000000000000000000000000000000000000000000000001",
+			kind:      RevertKindPanic,
+			reason:    "assert failed",
+			panicCode: 0x01,
+			str:       "panic 0x1: assert failed",
+		},
+		{
+			name:      "unknown panic code",
+			data:      "0x4e487b71" + "00000000000000000000000000000000000000000000000000000000000000ff",
+			kind:      RevertKindPanic,
+			reason:    "unknown panic code",
+			panicCode: 0xff,
+			str:       "panic 0xff: unknown panic code",
+		},
+		{
+			name: "truncated panic",
+			data: "0x4e487b710000",
+			kind: RevertKindUnknown,
+			str:  "unknown revert data 0x4e487b710000",
+		},
+		{
+			name: "custom error",
+			data: "0xcf479181" + "0000000000000000000000000000000000000000000000000000000000000001" + "0000000000000000000000000000000000000000000000000000000000000002",
+			abis: []*abi.ABI{nil, &customAbi},
+			kind: RevertKindCustom,
+			str:  "InsufficientBalance[1 2]",
+		},
+		{
+			name: "custom error without abi",
+			data: "0xcf479181",
+			kind: RevertKindUnknown,
+			str:  "unknown revert data 0xcf479181",
+		},
+		{
+			name: "empty",
+			data: "0x",
+			kind: RevertKindUnknown,
+			str:  "reverted without reason",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := DecodeRevertData(hexutil.MustDecode(tt.data), tt.abis...)
+			if result.Kind != tt.kind {
+				t.Fatalf("kind = %s, want %s", result.Kind, tt.kind)
+			}
+			if result.Reason != tt.reason {
+				t.Errorf("reason = %q, want %q", result.Reason, tt.reason)
+			}
+			if tt.kind == RevertKindPanic && result.PanicCode.Cmp(big.NewInt(tt.panicCode)) != 0 {
+				t.Errorf("panic code = %s, want %d", result.PanicCode, tt.panicCode)
+			}
+			if result.String() != tt.str {
+				t.Errorf("string = %q, want %q", result.String(), tt.str)
+			}
+		})
+	}
+}
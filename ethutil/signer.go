@@ -0,0 +1,190 @@
+package ethutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/warrior21st/go-utils/commonutil"
+)
+
+//交易和消息签名者,签名结果均为[R || S || V]格式,V为27或28
+type Signer interface {
+	Address() common.Address
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+	SignHash(hash []byte) ([]byte, error)
+	SignTypedData(typedData TypedData) ([]byte, error)
+}
+
+//计算EIP-712结构化数据的签名hash
+func TypedDataHash(typedData TypedData) ([]byte, error) {
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		return nil, err
+	}
+	typedDataHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	return crypto.Keccak256(SIGN_PREFIX_HEX1901, domainSeparator, typedDataHash), nil
+}
+
+//内存中私钥的签名者
+type PrivateKeySigner struct {
+	prv *ecdsa.PrivateKey
+}
+
+func NewPrivateKeySigner(prv *ecdsa.PrivateKey) *PrivateKeySigner {
+	return &PrivateKeySigner{prv: prv}
+}
+
+func NewPrivateKeySignerFromHex(privateKey string) *PrivateKeySigner {
+	return NewPrivateKeySigner(HexToECDSAPrivateKey(privateKey))
+}
+
+func (s *PrivateKeySigner) Address() common.Address {
+	return crypto.PubkeyToAddress(s.prv.PublicKey)
+}
+
+func (s *PrivateKeySigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), s.prv)
+}
+
+func (s *PrivateKeySigner) SignHash(hash []byte) ([]byte, error) {
+	sig, err := crypto.Sign(hash, s.prv)
+	if err != nil {
+		return nil, err
+	}
+	sig[64] += 27
+
+	return sig, nil
+}
+
+func (s *PrivateKeySigner) SignTypedData(typedData TypedData) ([]byte, error) {
+	hash, err := TypedDataHash(typedData)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.SignHash(hash)
+}
+
+//keystore文件的签名者,创建时解密私钥并只保存在内存中
+type KeystoreSigner struct {
+	*PrivateKeySigner
+}
+
+func NewKeystoreSigner(keystoreJson []byte, pwd string) *KeystoreSigner {
+	return &KeystoreSigner{PrivateKeySigner: NewPrivateKeySigner(DecryptKeystore(keystoreJson, pwd))}
+}
+
+func NewKeystoreSignerFromFile(keystoreFile string, pwd string) *KeystoreSigner {
+	return NewKeystoreSigner(commonutil.ReadFileBytes(keystoreFile), pwd)
+}
+
+//通过Clef兼容的json-rpc接口签名的远程签名者
+type RemoteSigner struct {
+	client  *rpc.Client
+	address common.Address
+}
+
+func NewRemoteSigner(endpoint string, address string) (*RemoteSigner, error) {
+	client, err := rpc.DialHTTP(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RemoteSigner{client: client, address: common.HexToAddress(address)}, nil
+}
+
+func (s *RemoteSigner) Address() common.Address {
+	return s.address
+}
+
+func (s *RemoteSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	data := hexutil.Bytes(tx.Data())
+	args := apitypes.SendTxArgs{
+		From:    common.NewMixedcaseAddress(s.address),
+		Gas:     hexutil.Uint64(tx.Gas()),
+		Value:   hexutil.Big(*tx.Value()),
+		Nonce:   hexutil.Uint64(tx.Nonce()),
+		Data:    &data,
+		ChainID: (*hexutil.Big)(chainID),
+	}
+	if tx.To() != nil {
+		to := common.NewMixedcaseAddress(*tx.To())
+		args.To = &to
+	}
+	if tx.Type() == types.LegacyTxType {
+		args.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	} else {
+		args.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
+		accessList := tx.AccessList()
+		args.AccessList = &accessList
+	}
+
+	result := struct {
+		Raw hexutil.Bytes `json:"raw"`
+	}{}
+	err := s.client.CallContext(context.Background(), &result, "account_signTransaction", args)
+	if err != nil {
+		return nil, err
+	}
+
+	signedTx := new(types.Transaction)
+	err = signedTx.UnmarshalBinary(result.Raw)
+	if err != nil {
+		return nil, err
+	}
+	if signedTx.Hash() == tx.Hash() {
+		return nil, errors.New("remote signer returned unsigned tx")
+	}
+
+	return signedTx, nil
+}
+
+//Clef不支持对任意hash签名
+func (s *RemoteSigner) SignHash(hash []byte) ([]byte, error) {
+	return nil, errors.New("remote signer not support signing raw hash")
+}
+
+func (s *RemoteSigner) SignTypedData(typedData TypedData) ([]byte, error) {
+	var sig hexutil.Bytes
+	err := s.client.CallContext(context.Background(), &sig, "account_signTypedData", common.NewMixedcaseAddress(s.address), typedData)
+	if err != nil {
+		return nil, err
+	}
+	if len(sig) != 65 {
+		return nil, fmt.Errorf("invalid signature length %d", len(sig))
+	}
+
+	return sig, nil
+}
+
+//使用Signer签名交易
+func SignTxBySigner(signer Signer, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	signedTx, err := signer.SignTx(tx, chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := types.LatestSignerForChainID(chainID).Sender(signedTx)
+	if err != nil {
+		return nil, err
+	}
+	if from != signer.Address() {
+		return nil, fmt.Errorf("signed tx sender %s not equals to signer %s", from.Hex(), signer.Address().Hex())
+	}
+
+	return signedTx, nil
+}
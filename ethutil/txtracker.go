@@ -0,0 +1,283 @@
+package ethutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	TxStatePending   = "pending"
+	TxStateIncluded  = "included"
+	TxStateConfirmed = "confirmed"
+	TxStateReorged   = "reorged"
+	TxStateDropped   = "dropped"
+)
+
+const (
+	DefaultTrackPollInterval = 3 * time.Second
+	DefaultTrackDroppedAfter = 5 * time.Minute
+)
+
+var (
+	ErrTrackTimeout = errors.New("track tx timeout")
+	//节点不支持finalized标签(合并前的链及部分L2、侧链)
+	ErrFinalizedUnsupported = errors.New("finalized block tag unsupported")
+)
+
+type TrackParams struct {
+	//确认数,包含交易的区块本身算1个确认,UseFinalized为false时<=0按1处理
+	Confirmations uint64
+	//为true时等待交易所在区块被finalized标签覆盖
+	UseFinalized bool
+	//为0时使用DefaultTrackPollInterval
+	PollInterval time.Duration
+	//节点既没有回执也查不到交易持续该时长后认为交易被丢弃,为0时使用DefaultTrackDroppedAfter
+	DroppedAfter time.Duration
+	//为0时不超时
+	Timeout time.Duration
+}
+
+//交易状态变化
+type TxStateEvent struct {
+	TxHash        string
+	State         string
+	Receipt       *types.Receipt
+	BlockNumber   uint64
+	BlockHash     common.Hash
+	Confirmations uint64
+	//TrackTxAsync跟踪出错结束(超时、ctx取消、节点不支持finalized)时最后一个事件的错误,State为出错前的状态
+	Err error
+}
+
+func (e *TxStateEvent) String() string {
+	if e.Err != nil {
+		return fmt.Sprintf("tx %s track err: %s", e.TxHash, e.Err.Error())
+	}
+	switch e.State {
+	case TxStateIncluded, TxStateConfirmed:
+		return fmt.Sprintf("tx %s %s in block %d (%s),confirmations: %d,status: %d", e.TxHash, e.State, e.BlockNumber, e.BlockHash.Hex(), e.Confirmations, e.Receipt.Status)
+	case TxStateReorged:
+		return fmt.Sprintf("tx %s reorged out of block %d (%s)", e.TxHash, e.BlockNumber, e.BlockHash.Hex())
+	}
+
+	return fmt.Sprintf("tx %s %s", e.TxHash, e.State)
+}
+
+//跟踪交易直到达到确认数、被finalized或被丢弃,每次状态变化时调用onChange(可为nil),确认数增加不算状态变化
+//返回最终状态,状态为TxStateConfirmed时调用者仍需检查Receipt.Status
+func TrackTx(client *rpc.Client, txId string, paras *TrackParams, onChange func(*TxStateEvent)) (*TxStateEvent, error) {
+	return TrackTxContext(context.Background(), client, txId, paras, onChange)
+}
+
+//同TrackTx,ctx取消时返回ctx.Err()
+func TrackTxContext(ctx context.Context, client *rpc.Client, txId string, paras *TrackParams, onChange func(*TxStateEvent)) (*TxStateEvent, error) {
+	if paras == nil {
+		paras = &TrackParams{}
+	}
+	pollInterval := paras.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = DefaultTrackPollInterval
+	}
+	droppedAfter := paras.DroppedAfter
+	if droppedAfter <= 0 {
+		droppedAfter = DefaultTrackDroppedAfter
+	}
+	confirmations := paras.Confirmations
+	if confirmations == 0 {
+		confirmations = 1
+	}
+
+	ethClient := ethclient.NewClient(client)
+	hash := common.HexToHash(txId)
+	start := time.Now()
+	lastSeen := time.Now()
+	var current *TxStateEvent
+	emit := func(e *TxStateEvent) {
+		if current != nil && current.State == e.State && current.BlockHash == e.BlockHash {
+			return
+		}
+		current = e
+		LogWithTime(e.String())
+		if onChange != nil {
+			onChange(e)
+		}
+	}
+
+	sleep := func() bool {
+		timer := time.NewTimer(pollInterval)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for paras.Timeout <= 0 || time.Since(start) < paras.Timeout {
+		if ctx.Err() != nil {
+			return current, ctx.Err()
+		}
+		receipt, err := ethClient.TransactionReceipt(ctx, hash)
+		if err != nil && err != ethereum.NotFound {
+			LogWithTime(fmt.Sprintf("get tx %s receipt err: %s,sleep...", txId, err.Error()))
+			sleep()
+			continue
+		}
+
+		if receipt == nil {
+			if current != nil && (current.State == TxStateIncluded || current.State == TxStateConfirmed) {
+				emit(&TxStateEvent{TxHash: txId, State: TxStateReorged, BlockNumber: current.BlockNumber, BlockHash: current.BlockHash})
+				lastSeen = time.Now()
+			}
+			_, _, err := ethClient.TransactionByHash(ctx, hash)
+			if err == nil {
+				lastSeen = time.Now()
+				if current == nil || current.State != TxStateReorged {
+					emit(&TxStateEvent{TxHash: txId, State: TxStatePending})
+				}
+			} else if err == ethereum.NotFound && time.Since(lastSeen) >= droppedAfter {
+				dropped := &TxStateEvent{TxHash: txId, State: TxStateDropped}
+				emit(dropped)
+				return dropped, nil
+			}
+			sleep()
+			continue
+		}
+		lastSeen = time.Now()
+
+		//回执所在区块已不在主链上
+		canonical, err := GetBlockRef(client, BlockAt(receipt.BlockNumber.Uint64()))
+		if err != nil {
+			LogWithTime(fmt.Sprintf("get block %d err: %s,sleep...", receipt.BlockNumber.Uint64(), err.Error()))
+			sleep()
+			continue
+		}
+		if canonical.Hash != receipt.BlockHash {
+			emit(&TxStateEvent{TxHash: txId, State: TxStateReorged, BlockNumber: receipt.BlockNumber.Uint64(), BlockHash: receipt.BlockHash})
+			sleep()
+			continue
+		}
+		if current != nil && current.BlockHash != (common.Hash{}) && current.BlockHash != receipt.BlockHash && current.State != TxStateReorged {
+			emit(&TxStateEvent{TxHash: txId, State: TxStateReorged, BlockNumber: current.BlockNumber, BlockHash: current.BlockHash})
+		}
+
+		event, err := receiptState(client, txId, receipt, confirmations, paras.UseFinalized)
+		if errors.Is(err, ErrFinalizedUnsupported) {
+			return current, err
+		}
+		if err != nil {
+			LogWithTime(fmt.Sprintf("get tx %s confirmations err: %s,sleep...", txId, err.Error()))
+			sleep()
+			continue
+		}
+		emit(event)
+		if event.State == TxStateConfirmed {
+			return event, nil
+		}
+		sleep()
+	}
+
+	return current, ErrTrackTimeout
+}
+
+//异步跟踪交易,状态变化通过channel返回,跟踪结束或ctx取消后关闭channel,不再读取channel时须取消ctx
+//跟踪出错结束时关闭channel前发送一个Err不为nil的事件
+func TrackTxAsync(ctx context.Context, client *rpc.Client, txId string, paras *TrackParams) <-chan *TxStateEvent {
+	events := make(chan *TxStateEvent, 16)
+	send := func(e *TxStateEvent) {
+		select {
+		case events <- e:
+		case <-ctx.Done():
+		}
+	}
+	go func() {
+		defer close(events)
+		final, err := TrackTxContext(ctx, client, txId, paras, send)
+		if err != nil {
+			event := &TxStateEvent{TxHash: txId}
+			if final != nil {
+				copied := *final
+				event = &copied
+			}
+			event.Err = err
+			send(event)
+		}
+	}()
+
+	return events
+}
+
+func receiptState(client *rpc.Client, txId string, receipt *types.Receipt, confirmations uint64, useFinalized bool) (*TxStateEvent, error) {
+	blockNumber := receipt.BlockNumber.Uint64()
+	head, err := GetBlockRef(client, LatestBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &TxStateEvent{
+		TxHash:      txId,
+		State:       TxStateIncluded,
+		Receipt:     receipt,
+		BlockNumber: blockNumber,
+		BlockHash:   receipt.BlockHash,
+	}
+	if head.Number >= blockNumber {
+		event.Confirmations = head.Number - blockNumber + 1
+	}
+
+	if useFinalized {
+		finalized, err := GetBlockRef(client, FinalizedBlock)
+		if isFinalizedUnsupported(err) {
+			return nil, fmt.Errorf("%w: %s", ErrFinalizedUnsupported, err.Error())
+		}
+		if err != nil {
+			return nil, err
+		}
+		if finalized.Number >= blockNumber {
+			event.State = TxStateConfirmed
+		}
+	} else if event.Confirmations >= confirmations {
+		event.State = TxStateConfirmed
+	}
+
+	return event, nil
+}
+
+//节点不支持该方法/参数、不认识finalized标签或返回空区块时重试也不会成功,限流、区块未同步等其他错误可以重试
+var finalizedUnsupportedErrors = []string{
+	"unknown block tag",
+	"invalid block tag",
+	"unsupported block tag",
+	"invalid block number",
+}
+
+func isFinalizedUnsupported(err error) bool {
+	if err == nil {
+		return false
+	}
+	if err == ethereum.NotFound {
+		return true
+	}
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && (rpcErr.ErrorCode() == -32601 || rpcErr.ErrorCode() == -32602) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range finalizedUnsupportedErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,87 @@
+package ethutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type testRPCError struct {
+	code int
+	msg  string
+}
+
+func (e *testRPCError) Error() string  { return e.msg }
+func (e *testRPCError) ErrorCode() int { return e.code }
+
+func TestIsFinalizedUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"null block", ethereum.NotFound, true},
+		{"method not found", &testRPCError{-32601, "the method eth_getBlockByNumber does not exist/is not available"}, true},
+		{"invalid params", &testRPCError{-32602, "invalid argument 0: hex string without 0x prefix"}, true},
+		{"unknown block tag", &testRPCError{-32000, "Unknown block tag finalized"}, true},
+		{"invalid block tag", errors.New("invalid block tag"), true},
+		{"rate limit", &testRPCError{-32005, "daily request count exceeded, request rate limited"}, false},
+		{"header not found", &testRPCError{-32000, "header not found"}, false},
+		{"http error", errors.New("429 Too Many Requests: rate limited"), false},
+		{"wrapped", fmt.Errorf("get finalized: %w", &testRPCError{-32601, "method not found"}), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFinalizedUnsupported(tt.err); got != tt.want {
+				t.Errorf("isFinalizedUnsupported(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+//一直查不到回执,但交易仍在交易池中的节点
+type pendingTxNode struct{}
+
+func (node *pendingTxNode) GetTransactionReceipt(hash common.Hash) interface{} {
+	return nil
+}
+
+func (node *pendingTxNode) GetTransactionByHash(hash common.Hash) map[string]interface{} {
+	return map[string]interface{}{"blockNumber": nil}
+}
+
+func TestTrackTxAsyncTimeout(t *testing.T) {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", &pendingTxNode{}); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	txId := "0x0000000000000000000000000000000000000000000000000000000000000001"
+	events := TrackTxAsync(ctx, client, txId, &TrackParams{PollInterval: 10 * time.Millisecond, Timeout: 50 * time.Millisecond})
+
+	var last *TxStateEvent
+	for e := range events {
+		last = e
+	}
+	if last == nil {
+		t.Fatal("no events")
+	}
+	if !errors.Is(last.Err, ErrTrackTimeout) {
+		t.Errorf("last event err = %v, want %v", last.Err, ErrTrackTimeout)
+	}
+	if last.TxHash != txId {
+		t.Errorf("last event tx = %s, want %s", last.TxHash, txId)
+	}
+}
@@ -0,0 +1,287 @@
+package ethutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+//EIP-712结构化数据,json格式与eth_signTypedData_v4及Clef的account_signTypedData一致
+type TypedData struct {
+	Types       map[string][]TypedDataType `json:"types"`
+	PrimaryType string                     `json:"primaryType"`
+	Domain      TypedDataDomain            `json:"domain"`
+	Message     map[string]interface{}     `json:"message"`
+}
+
+type TypedDataType struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type TypedDataDomain struct {
+	Name              string                `json:"name"`
+	Version           string                `json:"version"`
+	ChainId           *math.HexOrDecimal256 `json:"chainId"`
+	VerifyingContract string                `json:"verifyingContract"`
+	Salt              string                `json:"salt"`
+}
+
+var typedDataArrayRegexp = regexp.MustCompile(`^(.+)\[(\d*)\]$`)
+
+//domain中非空的字段
+func (domain *TypedDataDomain) Map() map[string]interface{} {
+	dataMap := map[string]interface{}{}
+	if domain.ChainId != nil {
+		dataMap["chainId"] = domain.ChainId
+	}
+	if len(domain.Name) > 0 {
+		dataMap["name"] = domain.Name
+	}
+	if len(domain.Version) > 0 {
+		dataMap["version"] = domain.Version
+	}
+	if len(domain.VerifyingContract) > 0 {
+		dataMap["verifyingContract"] = domain.VerifyingContract
+	}
+	if len(domain.Salt) > 0 {
+		dataMap["salt"] = domain.Salt
+	}
+
+	return dataMap
+}
+
+//结构体类型的编码,如 Mail(Person from,Person to,string contents)Person(string name,address wallet)
+func (typedData *TypedData) EncodeType(primaryType string) string {
+	deps := typedData.dependencies(primaryType, nil)
+	if len(deps) > 0 {
+		rest := deps[1:]
+		sort.Strings(rest)
+		deps = append([]string{primaryType}, rest...)
+	}
+
+	buffer := bytes.Buffer{}
+	for _, dep := range deps {
+		buffer.WriteString(dep)
+		buffer.WriteString("(")
+		for i, field := range typedData.Types[dep] {
+			if i > 0 {
+				buffer.WriteString(",")
+			}
+			buffer.WriteString(field.Type)
+			buffer.WriteString(" ")
+			buffer.WriteString(field.Name)
+		}
+		buffer.WriteString(")")
+	}
+
+	return buffer.String()
+}
+
+func (typedData *TypedData) dependencies(primaryType string, found []string) []string {
+	primaryType = strings.Split(primaryType, "[")[0]
+	for _, dep := range found {
+		if dep == primaryType {
+			return found
+		}
+	}
+	if typedData.Types[primaryType] == nil {
+		return found
+	}
+
+	found = append(found, primaryType)
+	for _, field := range typedData.Types[primaryType] {
+		found = typedData.dependencies(field.Type, found)
+	}
+
+	return found
+}
+
+func (typedData *TypedData) TypeHash(primaryType string) []byte {
+	return crypto.Keccak256([]byte(typedData.EncodeType(primaryType)))
+}
+
+//hashStruct(s) = keccak256(typeHash || encodeData(s))
+func (typedData *TypedData) HashStruct(primaryType string, data map[string]interface{}) ([]byte, error) {
+	if typedData.Types[primaryType] == nil {
+		return nil, fmt.Errorf("unknown type %s", primaryType)
+	}
+
+	buffer := bytes.Buffer{}
+	buffer.Write(typedData.TypeHash(primaryType))
+	for _, field := range typedData.Types[primaryType] {
+		encoded, err := typedData.encodeValue(field.Type, data[field.Name])
+		if err != nil {
+			return nil, fmt.Errorf("encode %s.%s err: %s", primaryType, field.Name, err.Error())
+		}
+		buffer.Write(encoded)
+	}
+
+	return crypto.Keccak256(buffer.Bytes()), nil
+}
+
+//按类型编码为32字节,数组、结构体、string和bytes编码为hash
+//结构体数组的每个元素按EIP-712取hashStruct,与go-ethereum v1.10.11的signer/core不同(其未对元素取hash)
+func (typedData *TypedData) encodeValue(fieldType string, value interface{}) ([]byte, error) {
+	if m := typedDataArrayRegexp.FindStringSubmatch(fieldType); m != nil {
+		rv := reflect.ValueOf(value)
+		if value == nil || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) {
+			return nil, fmt.Errorf("%v is not an array", value)
+		}
+		if m[2] != "" {
+			if n, _ := strconv.Atoi(m[2]); n != rv.Len() {
+				return nil, fmt.Errorf("array length %d not equals to %d", rv.Len(), n)
+			}
+		}
+		buffer := bytes.Buffer{}
+		for i := 0; i < rv.Len(); i++ {
+			encoded, err := typedData.encodeValue(m[1], rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			buffer.Write(encoded)
+		}
+		return crypto.Keccak256(buffer.Bytes()), nil
+	}
+
+	if typedData.Types[fieldType] != nil {
+		data, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%v is not a %s struct", value, fieldType)
+		}
+		return typedData.HashStruct(fieldType, data)
+	}
+
+	return encodeTypedPrimitive(fieldType, value)
+}
+
+func encodeTypedPrimitive(fieldType string, value interface{}) ([]byte, error) {
+	switch {
+	case fieldType == "address":
+		var addr common.Address
+		switch v := value.(type) {
+		case string:
+			if !common.IsHexAddress(v) {
+				return nil, fmt.Errorf("invalid address %s", v)
+			}
+			addr = common.HexToAddress(v)
+		case common.Address:
+			addr = v
+		default:
+			return nil, fmt.Errorf("invalid address %v", value)
+		}
+		return common.LeftPadBytes(addr.Bytes(), 32), nil
+	case fieldType == "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("invalid bool %v", value)
+		}
+		if b {
+			return common.LeftPadBytes([]byte{1}, 32), nil
+		}
+		return make([]byte, 32), nil
+	case fieldType == "string":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid string %v", value)
+		}
+		return crypto.Keccak256([]byte(s)), nil
+	case fieldType == "bytes":
+		b, err := typedBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(b), nil
+	case strings.HasPrefix(fieldType, "bytes"):
+		size, err := strconv.Atoi(fieldType[5:])
+		if err != nil || size < 1 || size > 32 {
+			return nil, fmt.Errorf("invalid type %s", fieldType)
+		}
+		b, err := typedBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != size {
+			return nil, fmt.Errorf("%s length %d not equals to %d", fieldType, len(b), size)
+		}
+		return common.RightPadBytes(b, 32), nil
+	case strings.HasPrefix(fieldType, "uint") || strings.HasPrefix(fieldType, "int"):
+		signed := strings.HasPrefix(fieldType, "int")
+		bits, err := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(fieldType, "u"), "int"))
+		if err != nil || bits < 8 || bits > 256 || bits%8 != 0 {
+			return nil, fmt.Errorf("invalid type %s", fieldType)
+		}
+		n, err := typedInteger(value)
+		if err != nil {
+			return nil, err
+		}
+		//uintN取值范围为[0,2^N),intN为[-2^(N-1),2^(N-1))
+		min, max := big.NewInt(0), new(big.Int).Lsh(big.NewInt(1), uint(bits))
+		if signed {
+			max.Rsh(max, 1)
+			min.Neg(max)
+		}
+		if n.Cmp(min) < 0 || n.Cmp(max) >= 0 {
+			return nil, fmt.Errorf("value %s out of %s range", n.String(), fieldType)
+		}
+		if n.Sign() < 0 {
+			return math.U256Bytes(new(big.Int).Set(n)), nil
+		}
+		return math.PaddedBigBytes(n, 32), nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", fieldType)
+}
+
+func typedBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case hexutil.Bytes:
+		return v, nil
+	case string:
+		return hexutil.Decode(v)
+	}
+
+	return nil, fmt.Errorf("invalid bytes %v", value)
+}
+
+//整数可以是10进制或0x开头的16进制字符串、json数字或*big.Int
+func typedInteger(value interface{}) (*big.Int, error) {
+	switch v := value.(type) {
+	case *big.Int:
+		return v, nil
+	case *math.HexOrDecimal256:
+		return (*big.Int)(v), nil
+	case string:
+		n, ok := math.ParseBig256(v)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %s", v)
+		}
+		return n, nil
+	case float64:
+		if v != float64(int64(v)) {
+			return nil, fmt.Errorf("invalid integer %v", v)
+		}
+		return big.NewInt(int64(v)), nil
+	case int:
+		return big.NewInt(int64(v)), nil
+	case int64:
+		return big.NewInt(v), nil
+	case uint64:
+		return new(big.Int).SetUint64(v), nil
+	}
+
+	return nil, errors.New("invalid integer")
+}
@@ -0,0 +1,189 @@
+package ethutil
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+//EIP-712规范中的Mail示例
+const mailTypedDataJson = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": "1",
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+//eth-sig-util中signTypedData_v4的示例,包含结构体数组和地址数组
+const mailArrayTypedDataJson = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallets", "type": "address[]"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person[]"},
+			{"name": "contents", "type": "string"}
+		],
+		"Group": [
+			{"name": "name", "type": "string"},
+			{"name": "members", "type": "Person[]"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": "1",
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {
+			"name": "Cow",
+			"wallets": ["0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826", "0xDeaDbeefdEAdbeefdEadbEEFdeadbeEFdEaDbeeF"]
+		},
+		"to": [{
+			"name": "Bob",
+			"wallets": ["0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB", "0xB0BdaBea57B0BDABeA57b0bdABEA57b0BDabEa57", "0xB0B0b0b0b0b0B000000000000000000000000000"]
+		}],
+		"contents": "Hello, Bob!"
+	}
+}`
+
+func TestTypedDataHash(t *testing.T) {
+	tests := []struct {
+		name            string
+		json            string
+		encodeType      string
+		domainSeparator string
+		structHash      string
+		digest          string
+	}{
+		{
+			name:            "mail",
+			json:            mailTypedDataJson,
+			encodeType:      "Mail(Person from,Person to,string contents)Person(string name,address wallet)",
+			domainSeparator: "0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f",
+			structHash:      "0xc52c0ee5d84264471806290a3f2c4cecfc5490626bf912d01f240d7a274b371e",
+			digest:          "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2",
+		},
+		{
+			name:            "struct array",
+			json:            mailArrayTypedDataJson,
+			encodeType:      "Mail(Person from,Person[] to,string contents)Person(string name,address[] wallets)",
+			domainSeparator: "0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f",
+			structHash:      "0xeb4221181ff3f1a83ea7313993ca9218496e424604ba9492bb4052c03d5c3df8",
+			digest:          "0xa85c2e2b118698e88db68a8105b794a8cc7cec074e89ef991cb4f5f533819cc2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var typedData TypedData
+			if err := json.Unmarshal([]byte(tt.json), &typedData); err != nil {
+				t.Fatal(err)
+			}
+
+			if encodeType := typedData.EncodeType(typedData.PrimaryType); encodeType != tt.encodeType {
+				t.Errorf("encodeType = %s, want %s", encodeType, tt.encodeType)
+			}
+			domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hexutil.Encode(domainSeparator) != tt.domainSeparator {
+				t.Errorf("domain separator = %s, want %s", hexutil.Encode(domainSeparator), tt.domainSeparator)
+			}
+			structHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hexutil.Encode(structHash) != tt.structHash {
+				t.Errorf("struct hash = %s, want %s", hexutil.Encode(structHash), tt.structHash)
+			}
+			digest, err := TypedDataHash(typedData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hexutil.Encode(digest) != tt.digest {
+				t.Errorf("digest = %s, want %s", hexutil.Encode(digest), tt.digest)
+			}
+		})
+	}
+}
+
+func TestEncodeTypedInteger(t *testing.T) {
+	tests := []struct {
+		fieldType string
+		value     interface{}
+		want      string
+		wantErr   bool
+	}{
+		{fieldType: "uint8", value: "255", want: "0x00000000000000000000000000000000000000000000000000000000000000ff"},
+		{fieldType: "uint8", value: "256", wantErr: true},
+		{fieldType: "uint8", value: float64(-1), wantErr: true},
+		{fieldType: "int8", value: float64(-128), want: "0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff80"},
+		{fieldType: "int8", value: float64(127), want: "0x000000000000000000000000000000000000000000000000000000000000007f"},
+		{fieldType: "int8", value: float64(128), wantErr: true},
+		{fieldType: "int8", value: float64(-129), wantErr: true},
+		{fieldType: "uint256", value: "0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", want: "0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"},
+		{fieldType: "int256", value: new(big.Int).Lsh(big.NewInt(1), 255), wantErr: true},
+		{fieldType: "int256", value: new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 255)), want: "0x8000000000000000000000000000000000000000000000000000000000000000"},
+		{fieldType: "uint7", value: float64(1), wantErr: true},
+		{fieldType: "uint264", value: float64(1), wantErr: true},
+		{fieldType: "uint0", value: float64(0), wantErr: true},
+		{fieldType: "uint", value: float64(1), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fieldType, func(t *testing.T) {
+			encoded, err := encodeTypedPrimitive(tt.fieldType, tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("%v: expected error, got %s", tt.value, hexutil.Encode(encoded))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%v: %s", tt.value, err.Error())
+			}
+			if hexutil.Encode(encoded) != tt.want {
+				t.Errorf("%v = %s, want %s", tt.value, hexutil.Encode(encoded), tt.want)
+			}
+		})
+	}
+}
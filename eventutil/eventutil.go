@@ -0,0 +1,386 @@
+package eventutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/warrior21st/blockchain-utils/ethutil"
+	"github.com/warrior21st/go-utils/commonutil"
+)
+
+const (
+	DefaultChunkSize    = 2000
+	DefaultMaxChunkSize = 10000
+	DefaultMaxRetries   = 5
+)
+
+//节点因结果过多或区块范围过大拒绝查询时的错误信息,不能包含限流错误(如HTTP 429 Too Many Requests、rate limit exceeded)的内容
+var tooManyResultsErrors = []string{
+	//geth、Infura
+	"query returned more than",
+	//Erigon
+	"query exceeds max results",
+	"query exceeds max block range",
+	//Alchemy、BSC
+	"response size",
+	//BSC、Ankr、QuickNode等
+	"block range",
+	"range is too large",
+	"logs matched by query exceeds limit",
+}
+
+type ScanParams struct {
+	Addresses []string
+	Abi       string
+	//事件名,为空时扫描ABI中的所有事件
+	Events []string
+	//额外的topic过滤条件,对应topic1之后的位置
+	Topics [][]common.Hash
+
+	FromBlock uint64
+	//为0时扫描到最新区块
+	ToBlock uint64
+
+	//每次查询的区块数,查询结果过多时自动减半,查询成功时逐步增大到MaxChunkSize
+	ChunkSize    uint64
+	MaxChunkSize uint64
+
+	//断点文件,保存下一个待扫描的区块,存在时从断点继续扫描
+	CheckpointFile string
+
+	//同一区块范围连续查询失败的最大重试次数,为0时使用DefaultMaxRetries
+	MaxRetries int
+	//无法解码的日志(如topic0相同但indexed参数数量不同的ERC721 Transfer)会被跳过并回调
+	OnDecodeError func(log types.Log, err error)
+}
+
+type DecodedLog struct {
+	Event       string
+	Address     common.Address
+	BlockNumber uint64
+	BlockHash   common.Hash
+	TxHash      common.Hash
+	TxIndex     uint
+	LogIndex    uint
+	Args        map[string]interface{}
+	Raw         types.Log
+
+	event *abi.Event
+}
+
+//将事件参数(包括indexed参数)解码到结构体,字段名为参数名的驼峰形式
+func (l *DecodedLog) Unpack(out interface{}) error {
+	if len(l.Raw.Data) > 0 {
+		values, err := l.event.Inputs.Unpack(l.Raw.Data)
+		if err != nil {
+			return err
+		}
+		err = l.event.Inputs.Copy(out, values)
+		if err != nil {
+			return err
+		}
+	}
+
+	var indexed abi.Arguments
+	for _, arg := range l.event.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+
+	return abi.ParseTopics(out, indexed, l.Raw.Topics[1:])
+}
+
+type scanner struct {
+	client   *ethclient.Client
+	paras    *ScanParams
+	contract *abi.ABI
+	events   map[common.Hash]*abi.Event
+}
+
+func newScanner(client *ethclient.Client, paras *ScanParams) (*scanner, error) {
+	contract := ethutil.GetContractAbi(paras.Abi)
+	events := make(map[common.Hash]*abi.Event)
+	if len(paras.Events) == 0 {
+		for name := range contract.Events {
+			event := contract.Events[name]
+			if !event.Anonymous {
+				events[event.ID] = &event
+			}
+		}
+	}
+	for _, name := range paras.Events {
+		event, ok := contract.Events[name]
+		if !ok {
+			return nil, fmt.Errorf("event %s not found in abi", name)
+		}
+		events[event.ID] = &event
+	}
+	if len(events) == 0 {
+		return nil, errors.New("no event to scan")
+	}
+
+	return &scanner{client: client, paras: paras, contract: contract, events: events}, nil
+}
+
+//按区块范围分段扫描事件并通过channel依次返回,扫描结束或出错时关闭channel,错误通过errCh返回
+//每段的日志全部被接收后才会写入断点,重新扫描时从断点继续
+func ScanLogs(ctx context.Context, client *ethclient.Client, paras *ScanParams) (<-chan *DecodedLog, <-chan error) {
+	logs := make(chan *DecodedLog)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(logs)
+		defer close(errCh)
+
+		s, err := newScanner(client, paras)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		err = s.run(ctx, logs)
+		if err != nil {
+			errCh <- err
+		}
+	}()
+
+	return logs, errCh
+}
+
+//扫描全部事件并返回
+func ScanAllLogs(client *ethclient.Client, paras *ScanParams) ([]*DecodedLog, error) {
+	logs, errCh := ScanLogs(context.Background(), client, paras)
+	results := make([]*DecodedLog, 0)
+	for l := range logs {
+		results = append(results, l)
+	}
+
+	return results, <-errCh
+}
+
+func (s *scanner) run(ctx context.Context, out chan<- *DecodedLog) error {
+	from := s.paras.FromBlock
+	checkpoint, ok, err := ReadCheckpoint(s.paras.CheckpointFile)
+	if err != nil {
+		return err
+	}
+	if ok && checkpoint > from {
+		from = checkpoint
+		ethutil.LogWithTime(fmt.Sprintf("resume scan logs from checkpoint block %d", from))
+	}
+	to := s.paras.ToBlock
+	if to == 0 {
+		latest, err := s.client.BlockNumber(ctx)
+		if err != nil {
+			return err
+		}
+		to = latest
+	}
+
+	chunkSize := s.paras.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+	maxChunkSize := s.paras.MaxChunkSize
+	if maxChunkSize == 0 {
+		maxChunkSize = DefaultMaxChunkSize
+	}
+	if maxChunkSize < chunkSize {
+		maxChunkSize = chunkSize
+	}
+
+	addresses := make([]common.Address, len(s.paras.Addresses))
+	for i := range s.paras.Addresses {
+		addresses[i] = common.HexToAddress(s.paras.Addresses[i])
+	}
+	eventIds := make([]common.Hash, 0, len(s.events))
+	for id := range s.events {
+		eventIds = append(eventIds, id)
+	}
+	topics := append([][]common.Hash{eventIds}, s.paras.Topics...)
+	maxRetries := s.paras.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = DefaultMaxRetries
+	}
+
+	retries := 0
+	for from <= to {
+		end := from + chunkSize - 1
+		if end > to {
+			end = to
+		}
+
+		rawLogs, err := s.client.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(end),
+			Addresses: addresses,
+			Topics:    topics,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if isTooManyResultsError(err) && chunkSize > 1 {
+				chunkSize /= 2
+				ethutil.LogWithTime(fmt.Sprintf("filter logs %d - %d too many results,shrink chunk size to %d...", from, end, chunkSize))
+				continue
+			}
+			retries++
+			if retries > maxRetries {
+				return fmt.Errorf("filter logs %d - %d err: %s", from, end, err.Error())
+			}
+			ethutil.LogWithTime(fmt.Sprintf("filter logs %d - %d err: %s,sleep 1s...", from, end, err.Error()))
+			time.Sleep(time.Second)
+			continue
+		}
+		retries = 0
+
+		skipped := 0
+		for i := range rawLogs {
+			decoded, err := s.decode(rawLogs[i])
+			if err != nil {
+				skipped++
+				if s.paras.OnDecodeError != nil {
+					s.paras.OnDecodeError(rawLogs[i], err)
+				}
+				continue
+			}
+			select {
+			case out <- decoded:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		err = WriteCheckpoint(s.paras.CheckpointFile, end+1)
+		if err != nil {
+			return fmt.Errorf("write checkpoint err: %s", err.Error())
+		}
+		ethutil.LogWithTime(fmt.Sprintf("scanned logs %d - %d / %d,found %d,skipped %d", from, end, to, len(rawLogs), skipped))
+
+		from = end + 1
+		if chunkSize < maxChunkSize {
+			chunkSize *= 2
+			if chunkSize > maxChunkSize {
+				chunkSize = maxChunkSize
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *scanner) decode(log types.Log) (*DecodedLog, error) {
+	if len(log.Topics) == 0 {
+		return nil, errors.New("log without topics")
+	}
+	event, ok := s.events[log.Topics[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown event %s", log.Topics[0].Hex())
+	}
+
+	return DecodeLog(event, log)
+}
+
+//按事件定义解码日志,indexed和非indexed参数都放入Args
+func DecodeLog(event *abi.Event, log types.Log) (*DecodedLog, error) {
+	args := make(map[string]interface{})
+	if len(log.Data) > 0 {
+		err := event.Inputs.UnpackIntoMap(args, log.Data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var indexed abi.Arguments
+	for _, arg := range event.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+	err := abi.ParseTopicsIntoMap(args, indexed, log.Topics[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &DecodedLog{
+		Event:       event.Name,
+		Address:     log.Address,
+		BlockNumber: log.BlockNumber,
+		BlockHash:   log.BlockHash,
+		TxHash:      log.TxHash,
+		TxIndex:     log.TxIndex,
+		LogIndex:    log.Index,
+		Args:        args,
+		Raw:         log,
+		event:       event,
+	}, nil
+}
+
+func isTooManyResultsError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, s := range tooManyResultsErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//读取断点文件中保存的区块号,文件不存在时返回false
+func ReadCheckpoint(filePath string) (uint64, bool, error) {
+	if commonutil.IsNilOrWhiteSpace(filePath) {
+		return 0, false, nil
+	}
+	content, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	block, err := strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid checkpoint file %s", filePath)
+	}
+
+	return block, true, nil
+}
+
+//先写入临时文件再重命名,避免写入中途退出导致断点文件损坏
+func WriteCheckpoint(filePath string, block uint64) error {
+	if commonutil.IsNilOrWhiteSpace(filePath) {
+		return nil
+	}
+
+	tmpFile := filePath + ".tmp"
+	f, err := os.OpenFile(tmpFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(strconv.FormatUint(block, 10))
+	if err == nil {
+		err = f.Sync()
+	}
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile, filePath)
+}
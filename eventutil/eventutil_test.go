@@ -0,0 +1,187 @@
+package eventutil
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const transferAbi = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Transfer","type":"event"}]`
+
+func TestIsTooManyResultsError(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want bool
+	}{
+		{"query returned more than 10000 results", true},
+		{"Log response size exceeded. You can make eth_getLogs requests with up to a 2K block range", true},
+		{"exceed maximum block range: 5000", true},
+		{"block range is too wide", true},
+		{"eth_getLogs block range too large, range: 100000, max: 10000", true},
+		{"query exceeds max results 20000", true},
+		{"logs matched by query exceeds limit of 10000", true},
+		{"429 Too Many Requests: {\"jsonrpc\":\"2.0\",\"error\":{\"code\":-32005}}", false},
+		{"daily request count exceeded, request rate limited", false},
+		{"rate limit exceeded", false},
+		{"header not found", false},
+	}
+
+	for _, tt := range tests {
+		if got := isTooManyResultsError(errors.New(tt.msg)); got != tt.want {
+			t.Errorf("isTooManyResultsError(%q) = %v, want %v", tt.msg, got, tt.want)
+		}
+	}
+}
+
+//区块范围超过maxRange时拒绝查询的模拟节点,failures中的错误依次在前几次查询时返回
+type logsTestNode struct {
+	head     uint64
+	maxRange uint64
+	failures []error
+	queried  [][2]uint64
+}
+
+func (node *logsTestNode) BlockNumber() hexutil.Uint64 {
+	return hexutil.Uint64(node.head)
+}
+
+func (node *logsTestNode) GetLogs(crit map[string]interface{}) ([]types.Log, error) {
+	from, err := hexutil.DecodeUint64(crit["fromBlock"].(string))
+	if err != nil {
+		return nil, err
+	}
+	to, err := hexutil.DecodeUint64(crit["toBlock"].(string))
+	if err != nil {
+		return nil, err
+	}
+	node.queried = append(node.queried, [2]uint64{from, to})
+	if len(node.failures) > 0 {
+		err := node.failures[0]
+		node.failures = node.failures[1:]
+		return nil, err
+	}
+	if to-from+1 > node.maxRange {
+		return nil, fmt.Errorf("query returned more than 10000 results")
+	}
+	return []types.Log{}, nil
+}
+
+func dialLogsTestNode(t *testing.T, node *logsTestNode) *ethclient.Client {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", node); err != nil {
+		t.Fatal(err)
+	}
+	client := ethclient.NewClient(rpc.DialInProc(server))
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestScanLogsChunkSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		node     *logsTestNode
+		paras    *ScanParams
+		expected [][2]uint64
+	}{
+		{
+			name:  "shrink on too many results and grow after success",
+			node:  &logsTestNode{maxRange: 6},
+			paras: &ScanParams{FromBlock: 0, ToBlock: 20, ChunkSize: 4, MaxChunkSize: 8},
+			expected: [][2]uint64{
+				{0, 3}, {4, 11}, {4, 7}, {8, 15}, {8, 11}, {12, 19}, {12, 15}, {16, 20},
+			},
+		},
+		{
+			name:     "throttled query retried without shrinking",
+			node:     &logsTestNode{maxRange: 100, failures: []error{errors.New("429 Too Many Requests")}},
+			paras:    &ScanParams{FromBlock: 0, ToBlock: 7, ChunkSize: 4, MaxChunkSize: 4},
+			expected: [][2]uint64{{0, 3}, {0, 3}, {4, 7}},
+		},
+		{
+			name:     "scan to head",
+			node:     &logsTestNode{head: 9, maxRange: 100},
+			paras:    &ScanParams{FromBlock: 5, ChunkSize: 2, MaxChunkSize: 100},
+			expected: [][2]uint64{{5, 6}, {7, 9}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.paras.Abi = transferAbi
+			_, err := ScanAllLogs(dialLogsTestNode(t, tt.node), tt.paras)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(tt.node.queried) != fmt.Sprint(tt.expected) {
+				t.Errorf("queried %v, want %v", tt.node.queried, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "checkpoint")
+
+	if _, ok, err := ReadCheckpoint(filePath); ok || err != nil {
+		t.Fatalf("missing checkpoint: ok = %v, err = %v", ok, err)
+	}
+	if _, ok, err := ReadCheckpoint(""); ok || err != nil {
+		t.Fatalf("empty path: ok = %v, err = %v", ok, err)
+	}
+	if err := WriteCheckpoint(filePath, 12345); err != nil {
+		t.Fatal(err)
+	}
+	if block, ok, err := ReadCheckpoint(filePath); !ok || err != nil || block != 12345 {
+		t.Fatalf("checkpoint = %d %v %v, want 12345", block, ok, err)
+	}
+
+	if err := ioutil.WriteFile(filePath, []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ReadCheckpoint(filePath); err == nil {
+		t.Errorf("expected error for invalid checkpoint")
+	}
+}
+
+func TestScanLogsCheckpoint(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "checkpoint")
+
+	node := &logsTestNode{maxRange: 100}
+	paras := &ScanParams{Abi: transferAbi, FromBlock: 0, ToBlock: 20, ChunkSize: 100, CheckpointFile: filePath}
+	if _, err := ScanAllLogs(dialLogsTestNode(t, node), paras); err != nil {
+		t.Fatal(err)
+	}
+	if block, _, _ := ReadCheckpoint(filePath); block != 21 {
+		t.Fatalf("checkpoint = %d, want 21", block)
+	}
+
+	//从断点继续扫描
+	node = &logsTestNode{maxRange: 100}
+	paras.ToBlock = 30
+	if _, err := ScanAllLogs(dialLogsTestNode(t, node), paras); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(node.queried) != fmt.Sprint([][2]uint64{{21, 30}}) {
+		t.Errorf("queried %v, want [[21 30]]", node.queried)
+	}
+
+	//断点文件损坏或无法写入时通过ScanLogs返回错误
+	if err := ioutil.WriteFile(filePath, []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ScanAllLogs(dialLogsTestNode(t, &logsTestNode{maxRange: 100}), paras); err == nil {
+		t.Errorf("expected error for invalid checkpoint")
+	}
+	paras.CheckpointFile = filepath.Join(t.TempDir(), "missing", "checkpoint")
+	if _, err := ScanAllLogs(dialLogsTestNode(t, &logsTestNode{maxRange: 100}), paras); err == nil {
+		t.Errorf("expected error for unwritable checkpoint")
+	}
+}
@@ -7,6 +7,7 @@ require (
 	github.com/ethereum/go-ethereum v1.10.11
 	github.com/google/uuid v1.1.5
 	github.com/shopspring/decimal v1.2.0
+	github.com/tyler-smith/go-bip39 v1.1.0
 	github.com/warrior21st/go-utils v0.0.7-0.20220308065104-68ad1fb2b3b4
 	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519 // indirect
 	golang.org/x/sys v0.0.0-20211020174200-9d6173849985 // indirect
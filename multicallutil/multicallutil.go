@@ -0,0 +1,236 @@
+package multicallutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/warrior21st/blockchain-utils/ethutil"
+	"github.com/warrior21st/blockchain-utils/tokenutil"
+)
+
+const (
+	Multicall3Abi = `[{"inputs":[{"components":[{"internalType":"address","name":"target","type":"address"},{"internalType":"bool","name":"allowFailure","type":"bool"},{"internalType":"bytes","name":"callData","type":"bytes"}],"internalType":"struct Multicall3.Call3[]","name":"calls","type":"tuple[]"}],"name":"aggregate3","outputs":[{"components":[{"internalType":"bool","name":"success","type":"bool"},{"internalType":"bytes","name":"returnData","type":"bytes"}],"internalType":"struct Multicall3.Result[]","name":"returnData","type":"tuple[]"}],"stateMutability":"payable","type":"function"},{"inputs":[{"internalType":"address","name":"addr","type":"address"}],"name":"getEthBalance","outputs":[{"internalType":"uint256","name":"balance","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"getBlockNumber","outputs":[{"internalType":"uint256","name":"blockNumber","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+
+	//Multicall3在绝大多数链上的部署地址
+	Multicall3Address = "0xcA11bde05977b3631167028862bE2a173976CA11"
+
+	DefaultChunkSize = 500
+)
+
+type Call3 struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type Result struct {
+	Success    bool
+	ReturnData []byte
+}
+
+type TokenMeta struct {
+	Token    string
+	Symbol   string
+	Decimals int32
+}
+
+//通过Multicall3.aggregate3批量调用,calls按chunkSize分批请求,返回结果与calls顺序一致
+func Aggregate3(client *ethclient.Client, multicall string, calls []Call3, chunkSize int) ([]Result, error) {
+	return aggregate3(func(msg ethereum.CallMsg) ([]byte, error) {
+		return client.CallContract(context.Background(), msg, nil)
+	}, multicall, calls, chunkSize)
+}
+
+//在指定区块执行Aggregate3,所有分段都基于同一区块,block为标签时建议先用ethutil.ResolveBlock固定区块hash
+func Aggregate3At(client *rpc.Client, multicall string, calls []Call3, chunkSize int, block ethutil.BlockTag) ([]Result, error) {
+	return aggregate3(func(msg ethereum.CallMsg) ([]byte, error) {
+		return ethutil.CallContractAt(client, msg, block)
+	}, multicall, calls, chunkSize)
+}
+
+func aggregate3(call func(msg ethereum.CallMsg) ([]byte, error), multicall string, calls []Call3, chunkSize int) ([]Result, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	multicallAbi := ethutil.GetContractAbi(Multicall3Abi)
+	multicallAddr := common.HexToAddress(multicall)
+	results := make([]Result, 0, len(calls))
+	for i := 0; i < len(calls); i += chunkSize {
+		endIndex := i + chunkSize
+		if endIndex > len(calls) {
+			endIndex = len(calls)
+		}
+
+		callData, err := multicallAbi.Pack("aggregate3", calls[i:endIndex])
+		if err != nil {
+			return nil, err
+		}
+		output, err := call(ethereum.CallMsg{
+			To:   &multicallAddr,
+			Data: callData,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("aggregate3 calls index %d - %d err: %s", i, endIndex-1, err.Error())
+		}
+
+		unpacked, err := multicallAbi.Unpack("aggregate3", output)
+		if err != nil {
+			return nil, err
+		}
+		chunkResults := *abi.ConvertType(unpacked[0], new([]Result)).(*[]Result)
+		if len(chunkResults) != endIndex-i {
+			return nil, errors.New("aggregate3 results length not equals to calls length")
+		}
+		results = append(results, chunkResults...)
+	}
+
+	return results, nil
+}
+
+//批量查询token余额,调用失败的项为nil
+func BalancesOf(client *ethclient.Client, multicall string, token string, accounts []string, chunkSize int) ([]*big.Int, error) {
+	calls, err := balanceOfCalls(token, accounts)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := Aggregate3(client, multicall, calls, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return unpackUint256Results(results), nil
+}
+
+//在指定区块批量查询token余额,调用失败的项为nil
+func BalancesOfAt(client *rpc.Client, multicall string, token string, accounts []string, chunkSize int, block ethutil.BlockTag) ([]*big.Int, error) {
+	calls, err := balanceOfCalls(token, accounts)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := Aggregate3At(client, multicall, calls, chunkSize, block)
+	if err != nil {
+		return nil, err
+	}
+
+	return unpackUint256Results(results), nil
+}
+
+func balanceOfCalls(token string, accounts []string) ([]Call3, error) {
+	erc20Abi := ethutil.GetContractAbi(tokenutil.ERC20Abi)
+	calls := make([]Call3, len(accounts))
+	for i := range accounts {
+		callData, err := erc20Abi.Pack("balanceOf", common.HexToAddress(accounts[i]))
+		if err != nil {
+			return nil, err
+		}
+		calls[i] = Call3{Target: common.HexToAddress(token), AllowFailure: true, CallData: callData}
+	}
+
+	return calls, nil
+}
+
+//批量查询原生币余额,调用失败的项为nil
+func EthBalances(client *ethclient.Client, multicall string, accounts []string, chunkSize int) ([]*big.Int, error) {
+	multicallAbi := ethutil.GetContractAbi(Multicall3Abi)
+	calls := make([]Call3, len(accounts))
+	for i := range accounts {
+		callData, err := multicallAbi.Pack("getEthBalance", common.HexToAddress(accounts[i]))
+		if err != nil {
+			return nil, err
+		}
+		calls[i] = Call3{Target: common.HexToAddress(multicall), AllowFailure: true, CallData: callData}
+	}
+
+	results, err := Aggregate3(client, multicall, calls, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return unpackUint256Results(results), nil
+}
+
+//批量查询owners对spender的授权额度,调用失败的项为nil
+func Allowances(client *ethclient.Client, multicall string, token string, owners []string, spender string, chunkSize int) ([]*big.Int, error) {
+	erc20Abi := ethutil.GetContractAbi(tokenutil.ERC20Abi)
+	calls := make([]Call3, len(owners))
+	for i := range owners {
+		callData, err := erc20Abi.Pack("allowance", common.HexToAddress(owners[i]), common.HexToAddress(spender))
+		if err != nil {
+			return nil, err
+		}
+		calls[i] = Call3{Target: common.HexToAddress(token), AllowFailure: true, CallData: callData}
+	}
+
+	results, err := Aggregate3(client, multicall, calls, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return unpackUint256Results(results), nil
+}
+
+//批量查询token的symbol和decimals,调用失败的项为nil
+func TokenMetas(client *ethclient.Client, multicall string, tokens []string, chunkSize int) ([]*TokenMeta, error) {
+	erc20Abi := ethutil.GetContractAbi(tokenutil.ERC20Abi)
+	symbolData, err := erc20Abi.Pack("symbol")
+	if err != nil {
+		return nil, err
+	}
+	decimalsData, err := erc20Abi.Pack("decimals")
+	if err != nil {
+		return nil, err
+	}
+
+	calls := make([]Call3, 0, len(tokens)*2)
+	for i := range tokens {
+		calls = append(calls,
+			Call3{Target: common.HexToAddress(tokens[i]), AllowFailure: true, CallData: symbolData},
+			Call3{Target: common.HexToAddress(tokens[i]), AllowFailure: true, CallData: decimalsData})
+	}
+
+	results, err := Aggregate3(client, multicall, calls, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	metas := make([]*TokenMeta, len(tokens))
+	for i := range tokens {
+		symbolResult, decimalsResult := results[i*2], results[i*2+1]
+		if !symbolResult.Success || !decimalsResult.Success || len(decimalsResult.ReturnData) != 32 {
+			continue
+		}
+		symbol, err := erc20Abi.Methods["symbol"].Outputs.Unpack(symbolResult.ReturnData)
+		if err != nil {
+			continue
+		}
+
+		metas[i] = &TokenMeta{
+			Token:    tokens[i],
+			Symbol:   symbol[0].(string),
+			Decimals: int32(big.NewInt(0).SetBytes(decimalsResult.ReturnData).Int64()),
+		}
+	}
+
+	return metas, nil
+}
+
+func unpackUint256Results(results []Result) []*big.Int {
+	values := make([]*big.Int, len(results))
+	for i := range results {
+		if results[i].Success && len(results[i].ReturnData) == 32 {
+			values[i] = big.NewInt(0).SetBytes(results[i].ReturnData)
+		}
+	}
+
+	return values
+}
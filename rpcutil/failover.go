@@ -0,0 +1,219 @@
+package rpcutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+//Pool.Client使用的http.RoundTripper,将每个请求发送到健康的http节点,节点故障时切换到下一个节点
+//eth_sendRawTransaction请求并发广播到多个节点
+type failoverTransport struct {
+	pool *Pool
+}
+
+type rpcResponse struct {
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	nodes := t.pool.httpNodes()
+	if len(nodes) == 0 {
+		return nil, ErrNoHealthyEndpoint
+	}
+	if isSendRawTxRequest(body) {
+		return t.broadcast(req, body, nodes)
+	}
+
+	var lastResp *http.Response
+	var lastErr error
+	for _, n := range nodes {
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+		resp, respBody, err := t.send(req, body, n)
+		nodeErr := err
+		if err == nil {
+			nodeErr = responseNodeError(resp.StatusCode, respBody)
+		}
+		if nodeErr == nil {
+			return resp, nil
+		}
+		if err == nil || IsNodeError(err) {
+			t.pool.markFailure(n, nodeErr)
+		}
+		lastResp, lastErr = resp, err
+	}
+
+	return lastResp, lastErr
+}
+
+//发送到指定节点并读取完整响应
+func (t *failoverTransport) send(req *http.Request, body []byte, n *poolNode) (*http.Response, []byte, error) {
+	u, err := url.Parse(n.endpoint.URL)
+	if err != nil {
+		return nil, nil, err
+	}
+	r := req.Clone(req.Context())
+	r.URL = u
+	r.Host = u.Host
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	for k, v := range n.endpoint.Headers {
+		r.Header.Set(k, v)
+	}
+
+	resp, err := n.roundTripper.RoundTrip(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	return resp, respBody, nil
+}
+
+//并发广播交易,优先返回节点接受的响应,其次是交易已存在的响应
+func (t *failoverTransport) broadcast(req *http.Request, body []byte, nodes []*poolNode) (*http.Response, error) {
+	if t.pool.paras.BroadcastCount > 0 && len(nodes) > t.pool.paras.BroadcastCount {
+		nodes = nodes[:t.pool.paras.BroadcastCount]
+	}
+
+	resps := make([]*http.Response, len(nodes))
+	bodies := make([][]byte, len(nodes))
+	errs := make([]error, len(nodes))
+	wg := sync.WaitGroup{}
+	for i, n := range nodes {
+		wg.Add(1)
+		go func(i int, n *poolNode) {
+			defer wg.Done()
+			resps[i], bodies[i], errs[i] = t.send(req, body, n)
+		}(i, n)
+	}
+	wg.Wait()
+
+	best := -1
+	bestRank := 0
+	for i, n := range nodes {
+		rank := 1
+		if errs[i] != nil {
+			if IsNodeError(errs[i]) {
+				t.pool.markFailure(n, errs[i])
+			}
+			continue
+		}
+		if nodeErr := responseNodeError(resps[i].StatusCode, bodies[i]); nodeErr != nil {
+			t.pool.markFailure(n, nodeErr)
+		} else if msg, ok := responseError(bodies[i]); !ok {
+			rank = 3
+		} else if strings.Contains(strings.ToLower(msg), "already known") {
+			rank = 2
+		} else {
+			rank = 1
+		}
+		if rank > bestRank {
+			best, bestRank = i, rank
+		}
+	}
+	if best < 0 {
+		return nil, errs[0]
+	}
+
+	return resps[best], nil
+}
+
+//http状态码或json-rpc错误表明节点故障时返回错误
+func responseNodeError(statusCode int, body []byte) error {
+	if statusCode == http.StatusTooManyRequests || statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden || statusCode >= 500 {
+		return fmt.Errorf("%d %s", statusCode, http.StatusText(statusCode))
+	}
+	if statusCode < 200 || statusCode >= 300 {
+		return nil
+	}
+
+	for _, resp := range parseResponses(body) {
+		if resp.Error != nil && isNodeStateError(resp.Error.Code, resp.Error.Message) {
+			return fmt.Errorf("%d %s", resp.Error.Code, resp.Error.Message)
+		}
+	}
+
+	return nil
+}
+
+//单个请求响应中的错误信息
+func responseError(body []byte) (string, bool) {
+	resps := parseResponses(body)
+	if len(resps) != 1 || resps[0].Error == nil {
+		return "", false
+	}
+
+	return resps[0].Error.Message, true
+}
+
+func parseResponses(body []byte) []*rpcResponse {
+	trimmed := bytes.TrimSpace(body)
+	resps := make([]*rpcResponse, 0)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		json.Unmarshal(trimmed, &resps)
+	} else {
+		resp := &rpcResponse{}
+		if json.Unmarshal(trimmed, resp) == nil {
+			resps = append(resps, resp)
+		}
+	}
+
+	return resps
+}
+
+func isSendRawTxRequest(body []byte) bool {
+	msgs := parseMethods(body)
+	return len(msgs) == 1 && msgs[0] == "eth_sendRawTransaction"
+}
+
+//json-rpc请求体中的方法名,批量请求返回每个调用的方法名
+func parseMethods(body []byte) []string {
+	type rpcMsg struct {
+		Method string `json:"method"`
+	}
+	var msgs []*rpcMsg
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		json.Unmarshal(trimmed, &msgs)
+	} else {
+		msg := &rpcMsg{}
+		if json.Unmarshal(trimmed, msg) == nil {
+			msgs = append(msgs, msg)
+		}
+	}
+
+	methods := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg != nil && msg.Method != "" {
+			methods = append(methods, msg.Method)
+		}
+	}
+
+	return methods
+}
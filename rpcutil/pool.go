@@ -0,0 +1,529 @@
+package rpcutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/warrior21st/blockchain-utils/ethutil"
+)
+
+const (
+	DefaultMaxHeadLag     = 5
+	DefaultHealthInterval = 15 * time.Second
+	DefaultRequestTimeout = 10 * time.Second
+)
+
+var ErrNoHealthyEndpoint = errors.New("no healthy endpoint")
+
+type Endpoint struct {
+	URL string
+	//请求头,如 Authorization: Bearer xxx
+	Headers map[string]string
+	//http节点的限速参数,为nil时不限速也不统计
+	RateLimit *RateLimitParams
+}
+
+type PoolParams struct {
+	Endpoints []*Endpoint
+	//期望的chainId,为nil时以第一个可用节点为准
+	ChainID *big.Int
+	//落后最高区块超过该数量的节点视为不健康,为0时使用DefaultMaxHeadLag
+	MaxHeadLag uint64
+	//健康检查间隔,为0时使用DefaultHealthInterval
+	HealthInterval time.Duration
+	//健康检查请求超时,为0时使用DefaultRequestTimeout
+	RequestTimeout time.Duration
+	//发送交易时广播的节点数,<=0时广播到所有健康节点
+	BroadcastCount int
+}
+
+//节点状态
+type EndpointStatus struct {
+	URL      string
+	Healthy  bool
+	Head     uint64
+	Latency  time.Duration
+	Failures int
+	LastErr  string
+	//设置了RateLimit时的请求统计
+	Stats *CallStats
+}
+
+type poolNode struct {
+	endpoint  *Endpoint
+	rpcClient *rpc.Client
+	ethClient *ethclient.Client
+	transport *LimitedTransport
+	//http节点发送请求使用的transport,非http节点为nil
+	roundTripper http.RoundTripper
+
+	healthy  bool
+	head     uint64
+	latency  time.Duration
+	failures int
+	lastErr  error
+}
+
+//多节点客户端池,读请求路由到健康节点并在出错时自动切换,发送交易时广播到多个节点
+type Pool struct {
+	paras   *PoolParams
+	nodes   []*poolNode
+	chainId *big.Int
+	lock    sync.RWMutex
+	stop    chan struct{}
+	once    sync.Once
+
+	//通过failoverTransport在http节点间自动切换的客户端
+	rpcClient *rpc.Client
+	ethClient *ethclient.Client
+}
+
+func NewPool(paras *PoolParams) (*Pool, error) {
+	if len(paras.Endpoints) == 0 {
+		return nil, errors.New("no endpoint")
+	}
+
+	p := &Pool{paras: paras, stop: make(chan struct{})}
+	if paras.ChainID != nil {
+		p.chainId = new(big.Int).Set(paras.ChainID)
+	}
+	for _, endpoint := range paras.Endpoints {
+		rpcClient, transport, err := dialEndpoint(endpoint)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("dial %s err: %s", endpoint.URL, err.Error())
+		}
+		n := &poolNode{endpoint: endpoint, rpcClient: rpcClient, ethClient: ethclient.NewClient(rpcClient), transport: transport}
+		if isHTTPURL(endpoint.URL) {
+			n.roundTripper = http.DefaultTransport
+			if transport != nil {
+				n.roundTripper = transport
+			}
+		}
+		p.nodes = append(p.nodes, n)
+	}
+	for _, n := range p.nodes {
+		if n.roundTripper == nil {
+			continue
+		}
+		rpcClient, err := rpc.DialHTTPWithClient(n.endpoint.URL, &http.Client{Transport: &failoverTransport{pool: p}})
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.rpcClient = rpcClient
+		p.ethClient = ethclient.NewClient(rpcClient)
+		break
+	}
+
+	p.CheckHealth()
+	go p.healthLoop()
+
+	return p, nil
+}
+
+//按Endpoint连接节点并设置请求头
+func DialEndpoint(endpoint *Endpoint) (*rpc.Client, error) {
+	rpcClient, _, err := dialEndpoint(endpoint)
+
+	return rpcClient, err
+}
+
+func dialEndpoint(endpoint *Endpoint) (*rpc.Client, *LimitedTransport, error) {
+	var rpcClient *rpc.Client
+	var transport *LimitedTransport
+	var err error
+	if endpoint.RateLimit != nil && isHTTPURL(endpoint.URL) {
+		rpcClient, transport, err = DialWithRateLimit(endpoint.URL, endpoint.RateLimit)
+	} else {
+		rpcClient, err = rpc.Dial(endpoint.URL)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range endpoint.Headers {
+		rpcClient.SetHeader(k, v)
+	}
+
+	return rpcClient, transport, nil
+}
+
+//pool不为空时返回pool的客户端,否则连接endpoint;rateLimit不为空时所有请求都经过限速,返回的close函数不会关闭pool
+func DialClient(endpoint string, pool *Pool, rateLimit *RateLimitParams) (*ethclient.Client, func(), error) {
+	if pool != nil {
+		if rateLimit == nil || rateLimit.RequestsPerSecond <= 0 {
+			return pool.Client(), func() {}, nil
+		}
+		client, err := pool.LimitedClient(rateLimit)
+		if err != nil {
+			return nil, nil, err
+		}
+		return client, client.Close, nil
+	}
+	rpcClient, _, err := dialEndpoint(&Endpoint{URL: endpoint, RateLimit: rateLimit})
+	if err != nil {
+		return nil, nil, err
+	}
+	client := ethclient.NewClient(rpcClient)
+
+	return client, client.Close, nil
+}
+
+func isHTTPURL(rawurl string) bool {
+	url := strings.ToLower(rawurl)
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+func (p *Pool) Close() {
+	p.once.Do(func() {
+		close(p.stop)
+		for _, n := range p.nodes {
+			n.rpcClient.Close()
+		}
+	})
+}
+
+func (p *Pool) ChainID() *big.Int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.chainId
+}
+
+func (p *Pool) healthLoop() {
+	interval := p.paras.HealthInterval
+	if interval <= 0 {
+		interval = DefaultHealthInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.CheckHealth()
+		}
+	}
+}
+
+//并发检查所有节点的chainId和最新区块,落后过多或chainId不一致的节点标记为不健康
+func (p *Pool) CheckHealth() {
+	timeout := p.paras.RequestTimeout
+	if timeout <= 0 {
+		timeout = DefaultRequestTimeout
+	}
+	type checkResult struct {
+		chainId *big.Int
+		head    uint64
+		latency time.Duration
+		err     error
+	}
+	results := make([]*checkResult, len(p.nodes))
+	wg := sync.WaitGroup{}
+	for i, n := range p.nodes {
+		wg.Add(1)
+		go func(i int, n *poolNode) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			start := time.Now()
+			r := &checkResult{}
+			r.chainId, r.err = n.ethClient.ChainID(ctx)
+			if r.err == nil {
+				r.head, r.err = n.ethClient.BlockNumber(ctx)
+			}
+			r.latency = time.Since(start)
+			results[i] = r
+		}(i, n)
+	}
+	wg.Wait()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	maxHead := uint64(0)
+	for _, r := range results {
+		if r.err != nil {
+			continue
+		}
+		if p.chainId == nil {
+			p.chainId = r.chainId
+		}
+		if r.chainId.Cmp(p.chainId) == 0 && r.head > maxHead {
+			maxHead = r.head
+		}
+	}
+	maxLag := p.paras.MaxHeadLag
+	if maxLag == 0 {
+		maxLag = DefaultMaxHeadLag
+	}
+
+	for i, n := range p.nodes {
+		r := results[i]
+		wasHealthy := n.healthy
+		n.latency = r.latency
+		switch {
+		case r.err != nil:
+			n.healthy, n.lastErr = false, r.err
+		case r.chainId.Cmp(p.chainId) != 0:
+			n.healthy, n.lastErr = false, fmt.Errorf("chainId %s not equals to %s", r.chainId.String(), p.chainId.String())
+		case r.head+maxLag < maxHead:
+			n.head = r.head
+			n.healthy, n.lastErr = false, fmt.Errorf("head %d lag behind %d", r.head, maxHead)
+		default:
+			n.head = r.head
+			n.healthy, n.lastErr, n.failures = true, nil, 0
+		}
+		if wasHealthy && !n.healthy {
+			ethutil.LogWithTime(fmt.Sprintf("endpoint %s unhealthy: %s", n.endpoint.URL, n.lastErr.Error()))
+		} else if !wasHealthy && n.healthy {
+			ethutil.LogWithTime(fmt.Sprintf("endpoint %s healthy,head: %d", n.endpoint.URL, n.head))
+		}
+	}
+}
+
+//按优先级排序的节点:健康的在前,区块高的在前,延迟低的在前
+func (p *Pool) orderedNodes() []*poolNode {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	nodes := make([]*poolNode, len(p.nodes))
+	copy(nodes, p.nodes)
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if a.healthy != b.healthy {
+			return a.healthy
+		}
+		if a.head != b.head {
+			return a.head > b.head
+		}
+		return a.latency < b.latency
+	})
+
+	return nodes
+}
+
+func (p *Pool) healthyNodes() []*poolNode {
+	nodes := make([]*poolNode, 0)
+	for _, n := range p.orderedNodes() {
+		p.lock.RLock()
+		healthy := n.healthy
+		p.lock.RUnlock()
+		if healthy {
+			nodes = append(nodes, n)
+		}
+	}
+
+	return nodes
+}
+
+//在http节点间自动切换的客户端,每个请求按健康状态选择节点,节点故障时切换到下一个节点,发送交易时广播到多个节点
+//可直接传给其他包中接收*ethclient.Client的方法;没有http节点时返回当前最优节点的客户端,不会自动切换
+func (p *Pool) Client() *ethclient.Client {
+	if p.ethClient != nil {
+		return p.ethClient
+	}
+	return p.orderedNodes()[0].ethClient
+}
+
+//与Client相同的自动切换客户端,通过该客户端的所有请求共用一个按paras限速的令牌桶,与各节点自身的限速叠加
+//没有http节点时返回错误,使用完后需要Close
+func (p *Pool) LimitedClient(paras *RateLimitParams) (*ethclient.Client, error) {
+	var url string
+	for _, n := range p.nodes {
+		if n.roundTripper != nil {
+			url = n.endpoint.URL
+			break
+		}
+	}
+	if url == "" {
+		return nil, errors.New("no http endpoint in pool")
+	}
+
+	transport := NewLimitedTransport(&failoverTransport{pool: p}, paras)
+	transport.bucket = newTokenBucket(paras.RequestsPerSecond, paras.Burst)
+	rpcClient, err := rpc.DialHTTPWithClient(url, &http.Client{Transport: transport})
+	if err != nil {
+		return nil, err
+	}
+
+	return ethclient.NewClient(rpcClient), nil
+}
+
+func (p *Pool) RPC() *rpc.Client {
+	if p.rpcClient != nil {
+		return p.rpcClient
+	}
+	return p.orderedNodes()[0].rpcClient
+}
+
+//按优先级排序的http节点,有健康节点时只返回健康节点
+func (p *Pool) httpNodes() []*poolNode {
+	nodes := make([]*poolNode, 0)
+	for _, n := range p.healthyNodes() {
+		if n.roundTripper != nil {
+			nodes = append(nodes, n)
+		}
+	}
+	if len(nodes) > 0 {
+		return nodes
+	}
+	for _, n := range p.orderedNodes() {
+		if n.roundTripper != nil {
+			nodes = append(nodes, n)
+		}
+	}
+
+	return nodes
+}
+
+//依次在健康节点上执行fn,节点故障时切换到下一个节点,合约revert等请求本身的错误直接返回
+func (p *Pool) Do(fn func(client *ethclient.Client) error) error {
+	return p.DoRPC(func(client *rpc.Client) error {
+		return fn(ethclient.NewClient(client))
+	})
+}
+
+func (p *Pool) DoRPC(fn func(client *rpc.Client) error) error {
+	nodes := p.healthyNodes()
+	if len(nodes) == 0 {
+		nodes = p.orderedNodes()
+	}
+
+	var lastErr error
+	for _, n := range nodes {
+		err := fn(n.rpcClient)
+		if err == nil || !IsNodeError(err) {
+			return err
+		}
+		lastErr = err
+		p.markFailure(n, err)
+	}
+
+	return lastErr
+}
+
+func (p *Pool) markFailure(n *poolNode, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	n.failures++
+	n.lastErr = err
+	if n.healthy {
+		n.healthy = false
+		ethutil.LogWithTime(fmt.Sprintf("endpoint %s failed: %s,switch to next endpoint...", n.endpoint.URL, err.Error()))
+	}
+}
+
+//广播交易到多个健康节点,任一节点接受即成功
+func (p *Pool) SendTransaction(tx *types.Transaction) error {
+	nodes := p.healthyNodes()
+	if len(nodes) == 0 {
+		return ErrNoHealthyEndpoint
+	}
+	if p.paras.BroadcastCount > 0 && len(nodes) > p.paras.BroadcastCount {
+		nodes = nodes[:p.paras.BroadcastCount]
+	}
+
+	errs := make([]error, len(nodes))
+	wg := sync.WaitGroup{}
+	for i, n := range nodes {
+		wg.Add(1)
+		go func(i int, n *poolNode) {
+			defer wg.Done()
+			errs[i] = n.ethClient.SendTransaction(context.Background(), tx)
+			if errs[i] != nil && strings.Contains(strings.ToLower(errs[i].Error()), "already known") {
+				errs[i] = nil
+			}
+		}(i, n)
+	}
+	wg.Wait()
+
+	msgs := make([]string, 0)
+	for i, err := range errs {
+		if err == nil {
+			return nil
+		}
+		msgs = append(msgs, fmt.Sprintf("%s: %s", nodes[i].endpoint.URL, err.Error()))
+		if IsNodeError(err) {
+			p.markFailure(nodes[i], err)
+		}
+	}
+
+	return fmt.Errorf("broadcast tx %s failed: %s", tx.Hash().Hex(), strings.Join(msgs, "; "))
+}
+
+func (p *Pool) Status() []*EndpointStatus {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	statuses := make([]*EndpointStatus, len(p.nodes))
+	for i, n := range p.nodes {
+		statuses[i] = &EndpointStatus{URL: n.endpoint.URL, Healthy: n.healthy, Head: n.head, Latency: n.latency, Failures: n.failures}
+		if n.lastErr != nil {
+			statuses[i].LastErr = n.lastErr.Error()
+		}
+		if n.transport != nil {
+			statuses[i].Stats = n.transport.Stats()
+		}
+	}
+
+	return statuses
+}
+
+//部分节点在状态未同步、已裁剪或限流时返回的错误信息
+var nodeStateErrors = []string{"header not found", "missing trie node", "unknown block", "rate limit", "too many requests"}
+
+//节点自身的错误(网络、http状态、限流、状态缺失),这类错误换节点重试可能成功
+//解码失败等调用方自身的错误不属于节点错误
+func IsNodeError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF || err == rpc.ErrClientQuit || err == context.DeadlineExceeded {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return true
+	}
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		return isNodeStateError(rpcErr.ErrorCode(), err.Error())
+	}
+
+	return false
+}
+
+func isNodeStateError(code int, msg string) bool {
+	//-32005为限流
+	if code == -32005 {
+		return true
+	}
+	msg = strings.ToLower(msg)
+	for _, s := range nodeStateErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,303 @@
+package rpcutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/warrior21st/blockchain-utils/ethutil"
+)
+
+const (
+	DefaultMaxRetries   = 5
+	DefaultMaxRetryWait = 30 * time.Second
+)
+
+type RateLimitParams struct {
+	//每秒请求数,<=0时不限速
+	RequestsPerSecond float64
+	//突发请求数,<=0时为1
+	Burst int
+	//收到429后的最大重试次数,为0时使用DefaultMaxRetries,<0时不重试
+	MaxRetries int
+	//单次重试最长等待时间,为0时使用DefaultMaxRetryWait
+	MaxRetryWait time.Duration
+}
+
+//请求统计
+type CallStats struct {
+	//http请求数
+	Requests uint64
+	//json-rpc调用数,批量请求中每个调用单独计数
+	Calls uint64
+	//收到429的次数
+	Throttled uint64
+	Retries   uint64
+	//按方法统计的调用数
+	Methods map[string]uint64
+}
+
+//令牌桶
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	lock   sync.Mutex
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst <= 0 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+//取一个令牌,没有令牌时等待
+func (b *tokenBucket) wait(ctx context.Context) error {
+	b.lock.Lock()
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.lock.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		//未使用的令牌归还
+		b.lock.Lock()
+		b.tokens++
+		b.lock.Unlock()
+		return ctx.Err()
+	}
+}
+
+//按节点地址共享的令牌桶,同一地址的多个客户端共用限速
+var (
+	endpointBuckets    = make(map[string]*tokenBucket)
+	endpointBucketLock sync.Mutex
+)
+
+//节点地址对应的令牌桶,首次使用时按paras创建
+func endpointBucket(u *url.URL, paras *RateLimitParams) *tokenBucket {
+	key := strings.ToLower(u.Scheme+"://"+u.Host) + u.Path
+
+	endpointBucketLock.Lock()
+	defer endpointBucketLock.Unlock()
+	bucket, ok := endpointBuckets[key]
+	if !ok {
+		bucket = newTokenBucket(paras.RequestsPerSecond, paras.Burst)
+		endpointBuckets[key] = bucket
+	}
+
+	return bucket
+}
+
+//限速并统计请求的http.RoundTripper,收到429时按Retry-After自动重试
+//限速按请求的节点地址生效,同一地址的多个transport共用一个令牌桶,参数以首次使用时为准
+type LimitedTransport struct {
+	base  http.RoundTripper
+	paras *RateLimitParams
+	stats *CallStats
+	lock  sync.Mutex
+	//不为nil时使用该令牌桶,不按节点地址共享
+	bucket *tokenBucket
+}
+
+func NewLimitedTransport(base http.RoundTripper, paras *RateLimitParams) *LimitedTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if paras == nil {
+		paras = &RateLimitParams{}
+	}
+	return &LimitedTransport{base: base, paras: paras, stats: &CallStats{Methods: make(map[string]uint64)}}
+}
+
+//通过限速transport连接http节点
+func DialWithRateLimit(endpoint string, paras *RateLimitParams) (*rpc.Client, *LimitedTransport, error) {
+	transport := NewLimitedTransport(nil, paras)
+	rpcClient, err := rpc.DialHTTPWithClient(endpoint, &http.Client{Transport: transport})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rpcClient, transport, nil
+}
+
+func GetEthClientWithRateLimit(endpoint string, paras *RateLimitParams) (*ethclient.Client, *LimitedTransport, error) {
+	rpcClient, transport, err := DialWithRateLimit(endpoint, paras)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ethclient.NewClient(rpcClient), transport, nil
+}
+
+func (t *LimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	t.count(body)
+
+	maxRetries := t.paras.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	}
+	for attempt := 0; ; attempt++ {
+		if t.paras.RequestsPerSecond > 0 {
+			bucket := t.bucket
+			if bucket == nil {
+				bucket = endpointBucket(req.URL, t.paras)
+			}
+			if err := bucket.wait(req.Context()); err != nil {
+				return nil, err
+			}
+		}
+
+		r := req.Clone(req.Context())
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+		resp, err := t.base.RoundTrip(r)
+
+		t.lock.Lock()
+		t.stats.Requests++
+		if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+			t.stats.Throttled++
+		}
+		t.lock.Unlock()
+
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt >= maxRetries {
+			return resp, err
+		}
+
+		wait := t.retryWait(resp, attempt)
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		ethutil.LogWithTime(fmt.Sprintf("%s throttled,retry after %s...", req.URL.Host, wait.String()))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+
+		t.lock.Lock()
+		t.stats.Retries++
+		t.lock.Unlock()
+	}
+}
+
+//优先使用Retry-After(秒数或http时间),否则指数退避
+func (t *LimitedTransport) retryWait(resp *http.Response, attempt int) time.Duration {
+	maxWait := t.paras.MaxRetryWait
+	if maxWait <= 0 {
+		maxWait = DefaultMaxRetryWait
+	}
+
+	//避免重试次数较大时移位溢出
+	if attempt > 16 {
+		attempt = 16
+	}
+	wait := time.Second << uint(attempt)
+	if v := strings.TrimSpace(resp.Header.Get("Retry-After")); v != "" {
+		if secs, err := strconv.ParseFloat(v, 64); err == nil {
+			wait = time.Duration(secs * float64(time.Second))
+		} else if at, err := http.ParseTime(v); err == nil {
+			wait = time.Until(at)
+		}
+	}
+	if wait <= 0 {
+		wait = time.Second
+	}
+	if wait > maxWait {
+		wait = maxWait
+	}
+
+	return wait
+}
+
+//解析json-rpc请求体,统计方法调用数
+func (t *LimitedTransport) count(body []byte) {
+	methods := parseMethods(body)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, method := range methods {
+		t.stats.Calls++
+		t.stats.Methods[method]++
+	}
+}
+
+//当前统计的副本
+func (t *LimitedTransport) Stats() *CallStats {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	stats := *t.stats
+	stats.Methods = make(map[string]uint64, len(t.stats.Methods))
+	for k, v := range t.stats.Methods {
+		stats.Methods[k] = v
+	}
+
+	return &stats
+}
+
+func (t *LimitedTransport) ResetStats() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.stats = &CallStats{Methods: make(map[string]uint64)}
+}
+
+//打印统计,方法按调用数降序
+func (s *CallStats) String() string {
+	methods := make([]string, 0, len(s.Methods))
+	for k := range s.Methods {
+		methods = append(methods, k)
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		if s.Methods[methods[i]] != s.Methods[methods[j]] {
+			return s.Methods[methods[i]] > s.Methods[methods[j]]
+		}
+		return methods[i] < methods[j]
+	})
+
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("requests: %d,calls: %d,throttled: %d,retries: %d", s.Requests, s.Calls, s.Throttled, s.Retries))
+	for _, m := range methods {
+		sb.WriteString(fmt.Sprintf("\n  %s: %d", m, s.Methods[m]))
+	}
+
+	return sb.String()
+}
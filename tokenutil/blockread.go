@@ -0,0 +1,82 @@
+package tokenutil
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/warrior21st/blockchain-utils/ethutil"
+)
+
+func NameAt(client *rpc.Client, token string, block ethutil.BlockTag) (string, error) {
+	return erc20StringAt(client, token, block, "name")
+}
+
+func SymbolAt(client *rpc.Client, token string, block ethutil.BlockTag) (string, error) {
+	return erc20StringAt(client, token, block, "symbol")
+}
+
+func DecimalsAt(client *rpc.Client, token string, block ethutil.BlockTag) (int32, error) {
+	result, err := erc20CallAt(client, token, block, "decimals")
+	if err != nil {
+		return 0, err
+	}
+
+	return int32(big.NewInt(0).SetBytes(result).Int64()), nil
+}
+
+//在指定区块查询token总量,block可为区块号、区块hash或latest/pending/safe/finalized
+func TotalSupplyAt(client *rpc.Client, token string, block ethutil.BlockTag) (*big.Int, error) {
+	result, err := erc20CallAt(client, token, block, "totalSupply")
+	if err != nil {
+		return nil, err
+	}
+
+	return big.NewInt(0).SetBytes(result), nil
+}
+
+func BalanceOfAt(client *rpc.Client, token string, account string, block ethutil.BlockTag) (*big.Int, error) {
+	result, err := erc20CallAt(client, token, block, "balanceOf", common.HexToAddress(account))
+	if err != nil {
+		return nil, err
+	}
+
+	return big.NewInt(0).SetBytes(result), nil
+}
+
+func AllowanceAt(client *rpc.Client, token string, owner string, spender string, block ethutil.BlockTag) (*big.Int, error) {
+	result, err := erc20CallAt(client, token, block, "allowance", common.HexToAddress(owner), common.HexToAddress(spender))
+	if err != nil {
+		return nil, err
+	}
+
+	return big.NewInt(0).SetBytes(result), nil
+}
+
+func erc20StringAt(client *rpc.Client, token string, block ethutil.BlockTag, method string) (string, error) {
+	result, err := erc20CallAt(client, token, block, method)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := ethutil.GetContractAbi(ERC20Abi).Methods[method].Outputs.Unpack(result)
+	if err != nil {
+		return "", err
+	}
+
+	return f[0].(string), nil
+}
+
+func erc20CallAt(client *rpc.Client, token string, block ethutil.BlockTag, method string, args ...interface{}) ([]byte, error) {
+	callData, err := ethutil.GetContractAbi(ERC20Abi).Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	contractAddr := common.HexToAddress(token)
+	return ethutil.CallContractAt(client, ethereum.CallMsg{
+		To:   &contractAddr,
+		Data: callData,
+	}, block)
+}
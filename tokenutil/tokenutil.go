@@ -2,7 +2,6 @@ package tokenutil
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum"
@@ -19,6 +18,7 @@ const (
 	TransferERC20DefaultGas = 60000
 )
 
+//最新区块的查询,指定区块查询使用对应的*At方法
 func Name(client *ethclient.Client, token string) (string, error) {
 	result, err := erc20Call(client, token, "name")
 	if err != nil {
@@ -103,7 +103,7 @@ func erc20Call(client *ethclient.Client, token string, method string, args ...in
 	return result, nil
 }
 
-func erc20Send(client *ethclient.Client, chainId *big.Int, priv *ecdsa.PrivateKey, token string, method string, nonce uint64, gas uint64, gasPrice *big.Int, args ...interface{}) (string, error) {
+func erc20Send(client *ethclient.Client, chainId *big.Int, signer ethutil.Signer, token string, method string, nonce uint64, gas uint64, gasPrice *big.Int, args ...interface{}) (string, error) {
 	contract := ethutil.GetContractAbi(ERC20Abi)
 
 	inputData, err := contract.Pack(method, args...)
@@ -112,22 +112,28 @@ func erc20Send(client *ethclient.Client, chainId *big.Int, priv *ecdsa.PrivateKe
 	}
 
 	tx := ethutil.NewTx(nonce, token, big.NewInt(0), gas, gasPrice, inputData)
-	signedTx := ethutil.SignTx(priv, tx, chainId)
+	signedTx, err := ethutil.SignTxBySigner(signer, tx, chainId)
+	if err != nil {
+		return "", err
+	}
 	txId := ethutil.GetRawTxHash(signedTx)
-	ethutil.SendRawTx(client, signedTx)
+	err = ethutil.SendRawTx(client, signedTx)
+	if err != nil {
+		return "", err
+	}
 
 	return txId, nil
 }
 
-func Approve(client *ethclient.Client, chainId *big.Int, priv *ecdsa.PrivateKey, token string, spender string, nonce uint64, gas uint64, gasPrice *big.Int) (string, error) {
+func Approve(client *ethclient.Client, chainId *big.Int, signer ethutil.Signer, token string, spender string, nonce uint64, gas uint64, gasPrice *big.Int) (string, error) {
 	bi := big.NewInt(2)
 	bi.Exp(bi, big.NewInt(256), nil)
 	bi.Sub(bi, big.NewInt(1))
 
-	return erc20Send(client, chainId, priv, token, "approve", nonce, gas, gasPrice, common.HexToAddress(spender), bi)
+	return erc20Send(client, chainId, signer, token, "approve", nonce, gas, gasPrice, common.HexToAddress(spender), bi)
 }
 
-func Transfer(client *ethclient.Client, priv *ecdsa.PrivateKey, token string, to string, transferAmount *big.Int, nonce uint64, gas int64, gasPrice *big.Int) (string, error) {
+func Transfer(client *ethclient.Client, signer ethutil.Signer, token string, to string, transferAmount *big.Int, nonce uint64, gas int64, gasPrice *big.Int) (string, error) {
 	erc20ContractAbi := ethutil.GetContractAbi(ERC20Abi)
 
 	inputData, err := erc20ContractAbi.Pack("transfer", common.HexToAddress(to), transferAmount)
@@ -137,13 +143,34 @@ func Transfer(client *ethclient.Client, priv *ecdsa.PrivateKey, token string, to
 	chainId := ethutil.GetChainID(client)
 
 	tx := ethutil.NewTx(nonce, token, big.NewInt(0), uint64(gas), gasPrice, inputData)
-	signedTx := ethutil.SignTx(priv, tx, chainId)
+	signedTx, err := ethutil.SignTxBySigner(signer, tx, chainId)
+	if err != nil {
+		return "", err
+	}
 	txId := ethutil.GetRawTxHash(signedTx)
-	ethutil.SendRawTx(client, signedTx)
+	err = ethutil.SendRawTx(client, signedTx)
+	if err != nil {
+		return "", err
+	}
 
 	return txId, nil
 }
 
+//预估转账token所需的gas
+func EstimateTransferGas(client *ethclient.Client, token string, from string, to string, transferAmount *big.Int) (uint64, error) {
+	inputData, err := ethutil.GetContractAbi(ERC20Abi).Pack("transfer", common.HexToAddress(to), transferAmount)
+	if err != nil {
+		return 0, err
+	}
+
+	contractAddr := common.HexToAddress(token)
+	return client.EstimateGas(context.Background(), ethereum.CallMsg{
+		From: common.HexToAddress(from),
+		To:   &contractAddr,
+		Data: inputData,
+	})
+}
+
 func ConvertAmount(amount *big.Int, decimals int32) decimal.Decimal {
 	return decimal.NewFromBigInt(amount, 0).DivRound(decimal.NewFromInt(10).Pow(decimal.NewFromInt32(decimals)), decimals)
 }
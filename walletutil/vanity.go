@@ -0,0 +1,249 @@
+package walletutil
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/warrior21st/blockchain-utils/ethutil"
+)
+
+const DefaultProgressInterval = 5 * time.Second
+
+//地址匹配规则,Prefix/Suffix不含0x,CaseSensitive为true时按EIP-55校验和大小写匹配
+type AddressPattern struct {
+	Prefix        string
+	Suffix        string
+	CaseSensitive bool
+}
+
+type VanityParams struct {
+	Pattern AddressPattern
+	//搜索的协程数,<=0时为1
+	Workers int
+	//打印进度的间隔,<=0时为DefaultProgressInterval
+	ProgressInterval time.Duration
+	//最多尝试次数,<=0时不限制
+	MaxAttempts uint64
+}
+
+type Create2Params struct {
+	VanityParams
+
+	//调用CREATE2的合约地址(工厂合约)
+	Deployer     string
+	InitCodeHash common.Hash
+	//salt固定的前缀字节,部分工厂合约要求salt以调用者地址开头
+	SaltPrefix []byte
+}
+
+var ErrMaxAttemptsReached = errors.New("max attempts reached")
+
+func (p *AddressPattern) validate() error {
+	for _, s := range []string{p.Prefix, p.Suffix} {
+		if len(s) > 40 {
+			return fmt.Errorf("pattern %s too long", s)
+		}
+		if _, err := hex.DecodeString(strings.Repeat("0", len(s)%2) + s); err != nil {
+			return fmt.Errorf("pattern %s is not hex", s)
+		}
+	}
+	if len(p.Prefix)+len(p.Suffix) > 40 {
+		return errors.New("pattern too long")
+	}
+
+	return nil
+}
+
+//期望的尝试次数,每个16进制字符1/16,区分大小写时每个字母额外1/2
+func (p *AddressPattern) Difficulty() float64 {
+	chars := p.Prefix + p.Suffix
+	difficulty := math.Pow(16, float64(len(chars)))
+	if p.CaseSensitive {
+		for _, c := range chars {
+			if (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+				difficulty *= 2
+			}
+		}
+	}
+
+	return difficulty
+}
+
+func (p *AddressPattern) Match(address common.Address) bool {
+	var hexAddr string
+	if p.CaseSensitive {
+		hexAddr = address.Hex()[2:]
+	} else {
+		hexAddr = hex.EncodeToString(address.Bytes())
+	}
+
+	return p.matchHex(hexAddr)
+}
+
+func (p *AddressPattern) matchHex(hexAddr string) bool {
+	if p.CaseSensitive {
+		return strings.HasPrefix(hexAddr, p.Prefix) && strings.HasSuffix(hexAddr, p.Suffix)
+	}
+
+	return strings.HasPrefix(hexAddr, strings.ToLower(p.Prefix)) && strings.HasSuffix(hexAddr, strings.ToLower(p.Suffix))
+}
+
+//尝试attempts次后至少找到一个的概率
+func MatchProbability(difficulty float64, attempts uint64) float64 {
+	return 1 - math.Exp(-float64(attempts)/difficulty)
+}
+
+//多核搜索匹配规则的外部账户地址
+func FindVanityWallet(paras *VanityParams) (*Wallet, error) {
+	if err := paras.Pattern.validate(); err != nil {
+		return nil, err
+	}
+
+	var found *Wallet
+	err := search(paras, func() func() func() {
+		return func() func() {
+			prv, err := crypto.GenerateKey()
+			if err != nil {
+				panic(err)
+			}
+			if !paras.Pattern.Match(crypto.PubkeyToAddress(prv.PublicKey)) {
+				return nil
+			}
+
+			return func() {
+				found = &Wallet{
+					Address:    ethutil.PubkeyToAddress(&prv.PublicKey),
+					PrivateKey: ethutil.ECDSAPrivateKeyToHex(prv),
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+//计算CREATE2部署地址
+func Create2Address(deployer string, salt common.Hash, initCodeHash common.Hash) common.Address {
+	return crypto.CreateAddress2(common.HexToAddress(deployer), salt, initCodeHash.Bytes())
+}
+
+//搜索使CREATE2部署地址匹配规则的salt
+func MineCreate2Salt(paras *Create2Params) (common.Hash, common.Address, error) {
+	if err := paras.Pattern.validate(); err != nil {
+		return common.Hash{}, common.Address{}, err
+	}
+	if len(paras.SaltPrefix) > 24 {
+		return common.Hash{}, common.Address{}, errors.New("salt prefix longer than 24 bytes")
+	}
+
+	deployer := common.HexToAddress(paras.Deployer)
+	var salt common.Hash
+	var addr common.Address
+	err := search(&paras.VanityParams, func() func() func() {
+		//每个协程使用随机起点,salt末8字节递增
+		var workerSalt common.Hash
+		_, err := rand.Read(workerSalt[:24])
+		if err != nil {
+			panic(err)
+		}
+		copy(workerSalt[:], paras.SaltPrefix)
+		counter := uint64(0)
+
+		return func() func() {
+			binary.BigEndian.PutUint64(workerSalt[24:], counter)
+			counter++
+			a := crypto.CreateAddress2(deployer, workerSalt, paras.InitCodeHash.Bytes())
+			if !paras.Pattern.Match(a) {
+				return nil
+			}
+
+			s := workerSalt
+			return func() {
+				salt, addr = s, a
+			}
+		}
+	})
+	if err != nil {
+		return common.Hash{}, common.Address{}, err
+	}
+
+	return salt, addr, nil
+}
+
+//按协程运行newWorker返回的尝试函数,尝试函数匹配时返回保存结果的函数,只有第一个匹配的结果会被保存
+func search(paras *VanityParams, newWorker func() func() func()) error {
+	workers := paras.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	interval := paras.ProgressInterval
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+	difficulty := paras.Pattern.Difficulty()
+	ethutil.LogWithTime(fmt.Sprintf("start search pattern %s...%s,difficulty: %.0f,workers: %d", paras.Pattern.Prefix, paras.Pattern.Suffix, difficulty, workers))
+
+	var attempts uint64
+	var done int32
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			try := newWorker()
+			for atomic.LoadInt32(&done) == 0 {
+				n := atomic.AddUint64(&attempts, 1)
+				if paras.MaxAttempts > 0 && n > paras.MaxAttempts {
+					return
+				}
+				save := try()
+				if save == nil {
+					continue
+				}
+
+				if atomic.CompareAndSwapInt32(&done, 0, 1) {
+					save()
+				}
+				return
+			}
+		}()
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	start := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-finished:
+			if atomic.LoadInt32(&done) == 0 {
+				return ErrMaxAttemptsReached
+			}
+			ethutil.LogWithTime(fmt.Sprintf("found after %d attempts in %s", atomic.LoadUint64(&attempts), time.Since(start).Round(time.Second)))
+			return nil
+		case <-ticker.C:
+			n := atomic.LoadUint64(&attempts)
+			speed := float64(n) / time.Since(start).Seconds()
+			eta := time.Duration(math.Max(difficulty*math.Ln2-float64(n), 0) / speed * float64(time.Second))
+			ethutil.LogWithTime(fmt.Sprintf("searched %d,speed: %.0f/s,probability: %.2f%%,50%% eta: %s", n, speed, MatchProbability(difficulty, n)*100, eta.Round(time.Second)))
+		}
+	}
+}
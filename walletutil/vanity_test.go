@@ -0,0 +1,86 @@
+package walletutil
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+//EIP-1014中的示例
+func TestCreate2Address(t *testing.T) {
+	tests := []struct {
+		deployer string
+		salt     string
+		initCode string
+		want     string
+	}{
+		{"0x0000000000000000000000000000000000000000", "0x0000000000000000000000000000000000000000000000000000000000000000", "0x00", "0x4D1A2e2bB4F88F0250f26Ffff098B0b30B26BF38"},
+		{"0xdeadbeef00000000000000000000000000000000", "0x0000000000000000000000000000000000000000000000000000000000000000", "0x00", "0xB928f69Bb1D91Cd65274e3c79d8986362984fDA3"},
+		{"0xdeadbeef00000000000000000000000000000000", "0x000000000000000000000000feed000000000000000000000000000000000000", "0x00", "0xD04116cDd17beBE565EB2422F2497E06cC1C9833"},
+		{"0x0000000000000000000000000000000000000000", "0x0000000000000000000000000000000000000000000000000000000000000000", "0xdeadbeef", "0x70f2b2914A2a4b783FaEFb75f459A580616Fcb5e"},
+		{"0x00000000000000000000000000000000deadbeef", "0x00000000000000000000000000000000000000000000000000000000cafebabe", "0xdeadbeef", "0x60f3f640a8508fC6a86d45DF051962668E1e8AC7"},
+		{"0x00000000000000000000000000000000deadbeef", "0x00000000000000000000000000000000000000000000000000000000cafebabe", "0xdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef", "0x1d8bfDC5D46DC4f61D6b6115972536eBE6A8854C"},
+		{"0x0000000000000000000000000000000000000000", "0x0000000000000000000000000000000000000000000000000000000000000000", "0x", "0xE33C0C7F7df4809055C3ebA6c09CFe4BaF1BD9e0"},
+	}
+
+	for _, tt := range tests {
+		initCodeHash := crypto.Keccak256Hash(hexutil.MustDecode(tt.initCode))
+		got := Create2Address(tt.deployer, common.HexToHash(tt.salt), initCodeHash)
+		if got.Hex() != tt.want {
+			t.Errorf("Create2Address(%s, %s, %s) = %s, want %s", tt.deployer, tt.salt, tt.initCode, got.Hex(), tt.want)
+		}
+	}
+}
+
+func TestAddressPatternMatch(t *testing.T) {
+	//校验和格式为0x52908400098527886E0F7030069857D2E4169EE7
+	address := common.HexToAddress("0x52908400098527886e0f7030069857d2e4169ee7")
+
+	tests := []struct {
+		name    string
+		pattern AddressPattern
+		want    bool
+	}{
+		{"empty pattern", AddressPattern{}, true},
+		{"prefix", AddressPattern{Prefix: "529084"}, true},
+		{"suffix", AddressPattern{Suffix: "69ee7"}, true},
+		{"prefix and suffix", AddressPattern{Prefix: "5290", Suffix: "ee7"}, true},
+		{"whole address", AddressPattern{Prefix: "52908400098527886e0f7030069857d2e4169ee7"}, true},
+		{"upper case ignored", AddressPattern{Suffix: "69EE7"}, true},
+		{"prefix mismatch", AddressPattern{Prefix: "529085"}, false},
+		{"suffix mismatch", AddressPattern{Suffix: "69ee8"}, false},
+		{"case sensitive checksum", AddressPattern{Suffix: "69EE7", CaseSensitive: true}, true},
+		{"case sensitive mixed", AddressPattern{Prefix: "52908400098527886E0F", CaseSensitive: true}, true},
+		{"case sensitive lower", AddressPattern{Suffix: "69ee7", CaseSensitive: true}, false},
+		{"case sensitive prefix lower", AddressPattern{Prefix: "52908400098527886e0f", CaseSensitive: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pattern.Match(address); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddressPatternDifficulty(t *testing.T) {
+	tests := []struct {
+		pattern AddressPattern
+		want    float64
+	}{
+		{AddressPattern{}, 1},
+		{AddressPattern{Prefix: "00"}, 256},
+		{AddressPattern{Prefix: "0", Suffix: "0"}, 256},
+		{AddressPattern{Prefix: "ab", CaseSensitive: true}, 1024},
+		{AddressPattern{Prefix: "ab"}, 256},
+	}
+
+	for _, tt := range tests {
+		if got := tt.pattern.Difficulty(); got != tt.want {
+			t.Errorf("%+v Difficulty = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package walletutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/warrior21st/blockchain-utils/ethutil"
+	"github.com/warrior21st/go-utils/commonutil"
+	"github.com/warrior21st/go-utils/fileutil"
+)
+
+const (
+	SourceRandom = "random"
+	SourceHD     = "hd"
+)
+
+type Wallet struct {
+	Address    string `json:"address"`
+	PrivateKey string `json:"privateKey,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Keystore   string `json:"keystore,omitempty"`
+}
+
+type GenerateParams struct {
+	Count int
+	//并发生成的协程数,<=1时为顺序执行
+	Concurrency int
+
+	//助记词不为空时从助记词派生索引[Start,Start+Count)的账户,否则随机生成
+	Mnemonic     string
+	Passphrase   string
+	PathTemplate string
+	Start        int
+
+	//每行为 地址,私钥 的csv文件,权限为0600
+	CsvFile string
+	//每行一个地址的文件
+	AddressFile string
+	//keystore保存目录及密码
+	KeystoreDir   string
+	KeystorePwd   string
+	KeystoreLight bool
+	//json清单文件,ManifestWithKeys为true时包含明文私钥,权限为0600
+	ManifestFile     string
+	ManifestWithKeys bool
+}
+
+type WalletManifest struct {
+	CreateTime   string    `json:"createTime"`
+	Source       string    `json:"source"`
+	PathTemplate string    `json:"pathTemplate,omitempty"`
+	Count        int       `json:"count"`
+	Wallets      []*Wallet `json:"wallets"`
+}
+
+//生成钱包并按参数写入各输出文件
+func GenerateWalletsToFiles(paras *GenerateParams) []*Wallet {
+	var wallets []*Wallet
+	if commonutil.IsNilOrWhiteSpace(paras.Mnemonic) {
+		wallets = GenerateWallets(paras.Count, paras.Concurrency)
+	} else {
+		wallets = GenerateHDWallets(paras.Mnemonic, paras.Passphrase, paras.PathTemplate, paras.Start, paras.Count, paras.Concurrency)
+	}
+
+	if !commonutil.IsNilOrWhiteSpace(paras.CsvFile) {
+		SaveWalletsCSV(paras.CsvFile, wallets)
+	}
+	if !commonutil.IsNilOrWhiteSpace(paras.AddressFile) {
+		SaveAddresses(paras.AddressFile, wallets)
+	}
+	if !commonutil.IsNilOrWhiteSpace(paras.KeystoreDir) {
+		SaveKeystores(ethutil.NewKeystoreStore(paras.KeystoreDir, paras.KeystoreLight), paras.KeystorePwd, wallets, paras.Concurrency)
+	}
+	if !commonutil.IsNilOrWhiteSpace(paras.ManifestFile) {
+		SaveManifest(paras.ManifestFile, NewWalletManifest(wallets, paras.PathTemplate), paras.ManifestWithKeys)
+	}
+
+	return wallets
+}
+
+//随机生成count个钱包
+func GenerateWallets(count int, concurrency int) []*Wallet {
+	wallets := make([]*Wallet, count)
+	ethutil.RunParallel(count, concurrency, func(i int) {
+		prv, err := crypto.GenerateKey()
+		if err != nil {
+			panic(err)
+		}
+		wallets[i] = &Wallet{
+			Address:    ethutil.PubkeyToAddress(&prv.PublicKey),
+			PrivateKey: ethutil.ECDSAPrivateKeyToHex(prv),
+		}
+		logProgress("generate wallet", i, count)
+	})
+
+	return wallets
+}
+
+//从助记词派生索引[start,start+count)的钱包,pathTemplate中的%d替换为索引,不包含%d时追加/%d
+func GenerateHDWallets(mnemonic string, passphrase string, pathTemplate string, start int, count int, concurrency int) []*Wallet {
+	if !ethutil.IsValidMnemonic(mnemonic) {
+		panic(fmt.Errorf("invalid mnemonic"))
+	}
+	if commonutil.IsNilOrWhiteSpace(pathTemplate) {
+		pathTemplate = ethutil.DefaultHDPathTemplate
+	}
+	if !strings.Contains(pathTemplate, "%d") {
+		pathTemplate = strings.TrimRight(pathTemplate, "/") + "/%d"
+	}
+
+	seed := ethutil.MnemonicToSeed(mnemonic, passphrase)
+	wallets := make([]*Wallet, count)
+	ethutil.RunParallel(count, concurrency, func(i int) {
+		path := fmt.Sprintf(pathTemplate, start+i)
+		prv, err := ethutil.DeriveKeyFromSeed(seed, path)
+		if err != nil {
+			panic(fmt.Errorf("derive %s err: %s", path, err.Error()))
+		}
+		wallets[i] = &Wallet{
+			Address:    ethutil.PubkeyToAddress(&prv.PublicKey),
+			PrivateKey: ethutil.ECDSAPrivateKeyToHex(prv),
+			Path:       path,
+		}
+		logProgress("derive wallet", i, count)
+	})
+
+	return wallets
+}
+
+//保存为每行 地址,私钥 的csv文件,文件仅所有者可读写
+func SaveWalletsCSV(filePath string, wallets []*Wallet) {
+	lines := make([]string, len(wallets))
+	for i, w := range wallets {
+		lines[i] = fmt.Sprintf("%s,%s", w.Address, w.PrivateKey)
+	}
+
+	writePrivateFile(filePath, []byte(strings.Join(lines, "\n")))
+}
+
+//保存为每行一个地址的文件
+func SaveAddresses(filePath string, wallets []*Wallet) {
+	sb := strings.Builder{}
+	for _, w := range wallets {
+		sb.WriteString(w.Address)
+		sb.WriteString("\n")
+	}
+
+	fileutil.WriteFile(filePath, sb.String())
+}
+
+//将钱包加密保存到keystore目录,并记录每个钱包的keystore文件路径
+func SaveKeystores(store *ethutil.KeystoreStore, pwd string, wallets []*Wallet, concurrency int) {
+	store.Reindex()
+	ethutil.RunParallel(len(wallets), concurrency, func(i int) {
+		wallets[i].Keystore = store.Import(ethutil.HexToECDSAPrivateKey(wallets[i].PrivateKey), pwd)
+		logProgress("save keystore", i, len(wallets))
+	})
+}
+
+func NewWalletManifest(wallets []*Wallet, pathTemplate string) *WalletManifest {
+	source := SourceRandom
+	if len(wallets) > 0 && wallets[0].Path != "" {
+		source = SourceHD
+		if commonutil.IsNilOrWhiteSpace(pathTemplate) {
+			pathTemplate = ethutil.DefaultHDPathTemplate
+		}
+	} else {
+		pathTemplate = ""
+	}
+
+	return &WalletManifest{
+		CreateTime:   time.Now().Format(time.RFC3339),
+		Source:       source,
+		PathTemplate: pathTemplate,
+		Count:        len(wallets),
+		Wallets:      wallets,
+	}
+}
+
+//保存json清单,withKeys为false时不包含明文私钥
+func SaveManifest(filePath string, manifest *WalletManifest, withKeys bool) {
+	if !withKeys {
+		copied := *manifest
+		copied.Wallets = make([]*Wallet, len(manifest.Wallets))
+		for i, w := range manifest.Wallets {
+			wallet := *w
+			wallet.PrivateKey = ""
+			copied.Wallets[i] = &wallet
+		}
+		manifest = &copied
+	}
+
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	if withKeys {
+		writePrivateFile(filePath, content)
+	} else {
+		fileutil.WriteFile(filePath, string(content))
+	}
+}
+
+//包含明文私钥的文件权限为0600
+func writePrivateFile(filePath string, content []byte) {
+	err := ioutil.WriteFile(filePath, content, 0600)
+	if err != nil {
+		panic(err)
+	}
+}
+
+//读取json清单
+func ReadManifest(filePath string) *WalletManifest {
+	manifest := &WalletManifest{}
+	err := json.Unmarshal(commonutil.ReadFileBytes(filePath), manifest)
+	if err != nil {
+		panic(err)
+	}
+
+	return manifest
+}
+
+//钱包的16进制私钥列表,可直接用于collectutil.HexKeySource
+func PrivateKeys(wallets []*Wallet) []string {
+	privs := make([]string, len(wallets))
+	for i := range wallets {
+		privs[i] = wallets[i].PrivateKey
+	}
+
+	return privs
+}
+
+func logProgress(action string, i int, total int) {
+	if (i+1)%1000 == 0 || i+1 == total {
+		ethutil.LogWithTime(fmt.Sprintf("%s progress %d/%d...", action, i+1, total))
+	}
+}
@@ -0,0 +1,36 @@
+package walletutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveWalletsCSV(t *testing.T) {
+	wallets := GenerateWallets(3, 2)
+	filePath := filepath.Join(t.TempDir(), "wallets.csv")
+	SaveWalletsCSV(filePath, wallets)
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %o, want 600", info.Mode().Perm())
+	}
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(content), "\n")
+	if len(lines) != len(wallets) {
+		t.Fatalf("lines = %d, want %d", len(lines), len(wallets))
+	}
+	for i, line := range lines {
+		if want := wallets[i].Address + "," + wallets[i].PrivateKey; line != want {
+			t.Errorf("line %d = %s, want %s", i, line, want)
+		}
+	}
+}